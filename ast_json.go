@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// AstJSON converts statements to a tree of maps
+// ready to be encoded with encoding/json.
+type AstJSON struct {
+	stmts []Stmt
+	node  map[string]any
+}
+
+func (aj *AstJSON) MarshalJSON() ([]byte, error) {
+	return json.Marshal(aj.list(aj.stmts))
+}
+
+func (aj *AstJSON) stmt(stmt Stmt) map[string]any {
+	if stmt == nil {
+		return nil
+	}
+
+	stmt.accept(aj)
+	return aj.node
+}
+
+func (aj *AstJSON) list(stmts []Stmt) []any {
+	nodes := []any{}
+	for _, stmt := range stmts {
+		nodes = append(nodes, aj.stmt(stmt))
+	}
+	return nodes
+}
+
+func (aj *AstJSON) expr(expr Expr) any {
+	if expr == nil {
+		return nil
+	}
+
+	return expr.accept(aj)
+}
+
+func (aj *AstJSON) token(token Token) map[string]any {
+	return map[string]any{
+		"type":   token.typ.String(),
+		"lexeme": token.lexeme,
+		"line":   token.line,
+		"column": token.column,
+	}
+}
+
+func (aj *AstJSON) params(params []Param) []any {
+	nodes := []any{}
+	for _, param := range params {
+		node := map[string]any{"name": aj.token(param.name), "value": aj.expr(param.value), "rest": param.rest}
+		aj.annotation(node, "type", param.typ)
+		if param.pattern != nil {
+			node["pattern"] = aj.pattern(param.pattern)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (aj *AstJSON) constant(node map[string]any, constant bool) {
+	if constant {
+		node["const"] = true
+	}
+}
+
+// annotation adds type to the node only when it's written,
+// so unannotated programs have the same tree as before.
+func (aj *AstJSON) annotation(node map[string]any, key string, typ *Annotation) {
+	if typ != nil {
+		node[key] = aj.token(typ.name)
+	}
+}
+
+func (aj *AstJSON) visitUnary(u *Unary) any {
+	return map[string]any{"node": "Unary", "op": aj.token(u.op), "right": aj.expr(u.right)}
+}
+
+func (aj *AstJSON) visitBinary(b *Binary) any {
+	return map[string]any{"node": "Binary", "left": aj.expr(b.left), "op": aj.token(b.op), "right": aj.expr(b.right)}
+}
+
+func (aj *AstJSON) visitLiteral(l *Literal) any {
+	return map[string]any{"node": "Literal", "token": aj.token(l.token), "value": l.value}
+}
+
+func (aj *AstJSON) visitGrouping(g *Grouping) any {
+	return map[string]any{"node": "Grouping", "expression": aj.expr(g.expression)}
+}
+
+func (aj *AstJSON) visitVariable(v *Variable) any {
+	return map[string]any{"node": "Variable", "name": aj.token(v.name)}
+}
+
+func (aj *AstJSON) visitAssignment(a *Assign) any {
+	return map[string]any{"node": "Assign", "variable": aj.token(a.variable), "value": aj.expr(a.value)}
+}
+
+func (aj *AstJSON) visitDestructure(d *Destructure) any {
+	return map[string]any{"node": "Destructure", "pattern": aj.pattern(d.pattern), "equal": aj.token(d.equal), "value": aj.expr(d.value)}
+}
+
+func (aj *AstJSON) visitList(l *ListExpr) any {
+	elements := []any{}
+	for _, element := range l.elements {
+		elements = append(elements, aj.expr(element))
+	}
+	return map[string]any{"node": "List", "bracket": aj.token(l.bracket), "elements": elements}
+}
+
+func (aj *AstJSON) visitLogical(l *Logical) any {
+	return map[string]any{"node": "Logical", "left": aj.expr(l.left), "operator": aj.token(l.operator), "right": aj.expr(l.right)}
+}
+
+func (aj *AstJSON) visitCall(c *Call) any {
+	args := []any{}
+	for _, arg := range c.args {
+		args = append(args, aj.expr(arg))
+	}
+	return map[string]any{"node": "Call", "callee": aj.expr(c.callee), "paren": aj.token(c.paren), "args": args}
+}
+
+func (aj *AstJSON) visitSpawn(s *Spawn) any {
+	return map[string]any{"node": "Spawn", "keyword": aj.token(s.keyword), "call": aj.expr(s.call)}
+}
+
+func (aj *AstJSON) visitMatch(m *Match) any {
+	cases := []any{}
+	for _, c := range m.cases {
+		cases = append(cases, map[string]any{"patterns": aj.patterns(c.patterns), "guard": aj.expr(c.guard), "body": aj.expr(c.body)})
+	}
+	return map[string]any{"node": "Match", "keyword": aj.token(m.keyword), "value": aj.expr(m.value), "cases": cases}
+}
+
+func (aj *AstJSON) patterns(patterns []Pattern) []any {
+	nodes := []any{}
+	for _, pattern := range patterns {
+		nodes = append(nodes, aj.pattern(pattern))
+	}
+	return nodes
+}
+
+func (aj *AstJSON) pattern(pattern Pattern) map[string]any {
+	switch p := pattern.(type) {
+	case *LiteralPattern:
+		return map[string]any{"pattern": "Literal", "value": p.value}
+	case *BindPattern:
+		return map[string]any{"pattern": "Bind", "name": aj.token(p.name)}
+	case *ListPattern:
+		node := map[string]any{"pattern": "List", "bracket": aj.token(p.bracket), "elements": aj.patterns(p.elements), "rest": nil}
+		if p.rest != nil {
+			node["rest"] = aj.pattern(p.rest)
+		}
+		return node
+	case *MapPattern:
+		return map[string]any{"pattern": "Map", "brace": aj.token(p.brace), "keys": p.keys, "values": aj.patterns(p.values)}
+	case *ClassPattern:
+		return map[string]any{"pattern": "Class", "class": aj.expr(p.class), "paren": aj.token(p.paren), "args": aj.patterns(p.args)}
+	}
+
+	return nil
+}
+
+func (aj *AstJSON) visitLambda(l *Lambda) any {
+	node := map[string]any{"node": "Lambda", "name": aj.token(l.name), "args": aj.params(l.args), "body": aj.list(l.body), "generator": l.generator}
+	aj.annotation(node, "returns", l.returns)
+	return node
+}
+
+func (aj *AstJSON) visitGet(g *Get) any {
+	return map[string]any{"node": "Get", "obj": aj.expr(g.obj), "name": aj.token(g.name)}
+}
+
+func (aj *AstJSON) visitSet(s *Set) any {
+	return map[string]any{"node": "Set", "obj": aj.expr(s.obj), "name": aj.token(s.name), "value": aj.expr(s.value)}
+}
+
+func (aj *AstJSON) visitSpread(s *Spread) any {
+	return map[string]any{"node": "Spread", "ellipsis": aj.token(s.ellipsis), "list": aj.expr(s.list)}
+}
+
+func (aj *AstJSON) visitThis(t *This) any {
+	return map[string]any{"node": "This", "keyword": aj.token(t.keyword)}
+}
+
+func (aj *AstJSON) visitPrintStmt(p *PrintStmt) *Completion {
+	aj.node = map[string]any{"node": "PrintStmt", "val": aj.expr(p.val)}
+	return nil
+}
+
+func (aj *AstJSON) visitExprStmt(es *ExprStmt) *Completion {
+	aj.node = map[string]any{"node": "ExprStmt", "expr": aj.expr(es.expr)}
+	return nil
+}
+
+func (aj *AstJSON) visitVarStmt(v *VarStmt) *Completion {
+	aj.node = map[string]any{"node": "VarStmt", "name": aj.token(v.name), "initializer": aj.expr(v.initializer)}
+	aj.annotation(aj.node, "type", v.typ)
+	aj.constant(aj.node, v.constant)
+	return nil
+}
+
+func (aj *AstJSON) visitDestructureStmt(d *DestructureStmt) *Completion {
+	aj.node = map[string]any{"node": "DestructureStmt", "pattern": aj.pattern(d.pattern), "initializer": aj.expr(d.initializer)}
+	aj.constant(aj.node, d.constant)
+	return nil
+}
+
+func (aj *AstJSON) visitBlockStmt(b *BlockStmt) *Completion {
+	aj.node = map[string]any{"node": "BlockStmt", "stmts": aj.list(b.stmts)}
+	return nil
+}
+
+func (aj *AstJSON) visitIfStmt(i *IfStmt) *Completion {
+	aj.node = map[string]any{"node": "IfStmt", "name": aj.token(i.name), "cond": aj.expr(i.cond), "then": aj.stmt(i.then), "or": aj.stmt(i.or)}
+	return nil
+}
+
+func (aj *AstJSON) visitEnvStmt(e *EnvStmt) *Completion {
+	aj.node = map[string]any{"node": "EnvStmt"}
+	return nil
+}
+
+func (aj *AstJSON) visitWhileStmt(w *WhileStmt) *Completion {
+	aj.node = map[string]any{"node": "WhileStmt", "cond": aj.expr(w.cond), "body": aj.stmt(w.body), "incr": aj.expr(w.incr)}
+	return nil
+}
+
+func (aj *AstJSON) visitForInStmt(f *ForInStmt) *Completion {
+	aj.node = map[string]any{"node": "ForInStmt", "keyword": aj.token(f.keyword), "name": aj.token(f.name), "iterable": aj.expr(f.iterable), "body": aj.stmt(f.body)}
+	return nil
+}
+
+func (aj *AstJSON) visitBreakStmt(b *BreakStmt) *Completion {
+	aj.node = map[string]any{"node": "BreakStmt", "keyword": aj.token(b.keyword)}
+	return nil
+}
+
+func (aj *AstJSON) visitContinueStmt(c *ContinueStmt) *Completion {
+	aj.node = map[string]any{"node": "ContinueStmt", "keyword": aj.token(c.keyword)}
+	return nil
+}
+
+func (aj *AstJSON) visitFunStmt(f *FunStmt) *Completion {
+	aj.node = map[string]any{"node": "FunStmt", "name": aj.token(f.name), "args": aj.params(f.args), "body": aj.list(f.body), "generator": f.generator}
+	aj.annotation(aj.node, "returns", f.returns)
+	aj.constant(aj.node, f.constant)
+	return nil
+}
+
+func (aj *AstJSON) visitReturnStmt(r *ReturnStmt) *Completion {
+	aj.node = map[string]any{"node": "ReturnStmt", "keyword": aj.token(r.keyword), "value": aj.expr(r.value)}
+	return nil
+}
+
+func (aj *AstJSON) visitYieldStmt(y *YieldStmt) *Completion {
+	aj.node = map[string]any{"node": "YieldStmt", "keyword": aj.token(y.keyword), "value": aj.expr(y.value)}
+	return nil
+}
+
+func (aj *AstJSON) visitClassStmt(c *ClassStmt) *Completion {
+	traits := []any{}
+	for _, trait := range c.traits {
+		traits = append(traits, aj.expr(trait))
+	}
+	aj.node = map[string]any{"node": "ClassStmt", "name": aj.token(c.name), "traits": traits, "methods": aj.methods(c.methods)}
+	aj.constant(aj.node, c.constant)
+	return nil
+}
+
+func (aj *AstJSON) visitTraitStmt(t *TraitStmt) *Completion {
+	aj.node = map[string]any{"node": "TraitStmt", "name": aj.token(t.name), "methods": aj.methods(t.methods)}
+	return nil
+}
+
+func (aj *AstJSON) methods(methods []Method) []any {
+	nodes := []any{}
+	for _, method := range methods {
+		node := aj.stmt(&method.FunStmt)
+		node["kind"] = []string{"method", "getter", "setter"}[method.kind]
+		node["static"] = method.static
+		node["override"] = method.override
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
@@ -8,6 +8,9 @@ import (
 type AstStringer struct {
 	str   strings.Builder
 	stmts []Stmt
+	// when set variables are printed with resolved
+	// scope distance and slot, like "a@1:0"
+	locals map[Expr]Slot
 }
 
 func (as *AstStringer) visitGet(g *Get) any {
@@ -24,7 +27,10 @@ func (as *AstStringer) visitSet(s *Set) any {
 
 func (as AstStringer) String() string {
 
-	for _, stmt := range as.stmts {
+	for idx, stmt := range as.stmts {
+		if idx > 0 {
+			as.str.WriteString("\n")
+		}
 		stmt.accept(&as)
 	}
 
@@ -44,7 +50,7 @@ func (as *AstStringer) visitBinary(b *Binary) any {
 }
 
 func (as *AstStringer) visitLiteral(l *Literal) any {
-	as.str.WriteString(fmt.Sprintf("%v", l.value))
+	as.str.WriteString(stringify(l.value))
 	return nil
 }
 
@@ -64,16 +70,112 @@ func (as *AstStringer) visitUnary(u *Unary) any {
 
 func (as *AstStringer) visitVariable(va *Variable) any {
 	as.str.WriteString(va.name.lexeme)
+	as.resolved(va)
+	return nil
+}
+
+// params prints parameters like "(a (= b 1) ...rest)"
+func (as *AstStringer) params(params []Param) {
+	if len(params) == 0 {
+		return
+	}
+
+	as.str.WriteString("(")
+	for i, param := range params {
+		if i > 0 {
+			as.str.WriteString(" ")
+		}
+
+		name := typed(param.name, param.typ)
+
+		if param.pattern != nil {
+			pattern := AstStringer{}
+			pattern.pattern(param.pattern)
+			name = pattern.str.String() + strings.TrimPrefix(name, param.name.lexeme)
+		}
+
+		switch {
+		case param.rest:
+			as.str.WriteString("..." + name)
+		case param.value != nil:
+			as.str.WriteString(fmt.Sprintf("(= %s ", name))
+			param.value.accept(as)
+			as.str.WriteString(")")
+		default:
+			as.str.WriteString(name)
+		}
+	}
+	as.str.WriteString(")")
+}
+
+// constant prints prefix of const declarations.
+func constant(constant bool) string {
+	if constant {
+		return "const "
+	}
+
+	return ""
+}
+
+// typed prints annotated name like "a:number".
+func typed(name Token, typ *Annotation) string {
+	if typ == nil {
+		return name.lexeme
+	}
+
+	return name.lexeme + ":" + typ.name.lexeme
+}
+
+// returns prints return type annotation like ":number".
+func (as *AstStringer) returns(typ *Annotation) {
+	if typ != nil {
+		as.str.WriteString(":" + typ.name.lexeme + " ")
+	}
+}
+
+func (as *AstStringer) visitSpread(s *Spread) any {
+	as.str.WriteString("...")
+	s.list.accept(as)
+	return nil
+}
+
+func (as *AstStringer) visitThis(t *This) any {
+	as.str.WriteString("this")
+	as.resolved(t)
 	return nil
 }
 
 func (as *AstStringer) visitAssignment(a *Assign) any {
-	as.str.WriteString(fmt.Sprintf("(= %s ", a.variable.lexeme))
+	as.str.WriteString(fmt.Sprintf("(= %s", a.variable.lexeme))
+	as.resolved(a)
+	as.str.WriteString(" ")
 	a.value.accept(as)
 	as.str.WriteString(")")
 	return nil
 }
 
+func (as *AstStringer) visitDestructure(d *Destructure) any {
+	as.str.WriteString("(= ")
+	as.pattern(d.pattern)
+	for _, target := range d.targets {
+		as.resolved(target)
+	}
+	as.str.WriteString(" ")
+	d.value.accept(as)
+	as.str.WriteString(")")
+	return nil
+}
+
+func (as *AstStringer) visitList(l *ListExpr) any {
+	as.str.WriteString("(list")
+	for _, element := range l.elements {
+		as.str.WriteString(" ")
+		element.accept(as)
+	}
+	as.str.WriteString(")")
+	return nil
+}
+
 func (as *AstStringer) visitLogical(l *Logical) any {
 	as.str.WriteString(fmt.Sprintf("(%s ", l.operator.lexeme))
 	l.left.accept(as)
@@ -100,18 +202,84 @@ func (as *AstStringer) visitCall(c *Call) any {
 	return nil
 }
 
-func (as *AstStringer) visitLambda(l *Lambda) any {
-	as.str.WriteString("(lambda ")
-	if len(l.args) != 0 {
-		as.str.WriteString("(")
-		for i, arg := range l.args {
-			as.str.WriteString(arg.lexeme)
-			if i < len(l.args)-1 {
+func (as *AstStringer) visitSpawn(s *Spawn) any {
+	as.str.WriteString("(spawn ")
+	s.call.accept(as)
+	as.str.WriteString(")")
+	return nil
+}
+
+func (as *AstStringer) visitMatch(m *Match) any {
+	as.str.WriteString("(match ")
+	m.value.accept(as)
+	for _, c := range m.cases {
+		as.str.WriteString(" (case")
+		for _, pattern := range c.patterns {
+			as.str.WriteString(" ")
+			as.pattern(pattern)
+		}
+		if c.guard != nil {
+			as.str.WriteString(" (if ")
+			c.guard.accept(as)
+			as.str.WriteString(")")
+		}
+		as.str.WriteString(" ")
+		c.body.accept(as)
+		as.str.WriteString(")")
+	}
+	as.str.WriteString(")")
+	return nil
+}
+
+// pattern prints patterns close to how they are written:
+// "[a b ...rest]", "{name: n}", "Point(x y)".
+func (as *AstStringer) pattern(pattern Pattern) {
+	switch p := pattern.(type) {
+	case *LiteralPattern:
+		as.str.WriteString(stringify(p.value))
+	case *BindPattern:
+		as.str.WriteString(p.name.lexeme)
+	case *ListPattern:
+		as.str.WriteString("[")
+		for idx, element := range p.elements {
+			if idx > 0 {
 				as.str.WriteString(" ")
 			}
+			as.pattern(element)
+		}
+		if p.rest != nil {
+			if len(p.elements) > 0 {
+				as.str.WriteString(" ")
+			}
+			as.str.WriteString("..." + p.rest.name.lexeme)
+		}
+		as.str.WriteString("]")
+	case *MapPattern:
+		as.str.WriteString("{")
+		for idx, key := range p.keys {
+			if idx > 0 {
+				as.str.WriteString(" ")
+			}
+			as.str.WriteString(key + ": ")
+			as.pattern(p.values[idx])
+		}
+		as.str.WriteString("}")
+	case *ClassPattern:
+		as.str.WriteString(p.class.name.lexeme + "(")
+		for idx, arg := range p.args {
+			if idx > 0 {
+				as.str.WriteString(" ")
+			}
+			as.pattern(arg)
 		}
 		as.str.WriteString(")")
 	}
+}
+
+func (as *AstStringer) visitLambda(l *Lambda) any {
+	as.str.WriteString("(lambda ")
+	as.params(l.args)
+	as.returns(l.returns)
 	for _, stmt := range l.body {
 		stmt.accept(as)
 	}
@@ -120,27 +288,47 @@ func (as *AstStringer) visitLambda(l *Lambda) any {
 	return nil
 }
 
-func (as *AstStringer) visitPrintStmt(p *PrintStmt) {
+func (as *AstStringer) visitPrintStmt(p *PrintStmt) *Completion {
 	as.str.WriteString("(print ")
 	p.val.accept(as)
 	as.str.WriteString(")")
+	return nil
 }
 
-func (as *AstStringer) visitExprStmt(se *ExprStmt) {
+func (as *AstStringer) visitExprStmt(se *ExprStmt) *Completion {
 	se.expr.accept(as)
+	return nil
 }
 
-func (as *AstStringer) visitVarStmt(vs *VarStmt) {
+func (as *AstStringer) visitVarStmt(vs *VarStmt) *Completion {
 	if vs.initializer != nil {
-		as.str.WriteString(fmt.Sprintf("(var %v ", vs.name.literal))
+		keyword := "var"
+		if vs.constant {
+			keyword = "const"
+		}
+		as.str.WriteString(fmt.Sprintf("(%s %s ", keyword, typed(vs.name, vs.typ)))
 		vs.initializer.accept(as)
 		as.str.WriteString(")")
 	} else {
-		as.str.WriteString(fmt.Sprintf("(var %v)", vs.name.literal))
+		as.str.WriteString(fmt.Sprintf("(var %s)", typed(vs.name, vs.typ)))
 	}
+	return nil
 }
 
-func (as *AstStringer) visitBlockStmt(b *BlockStmt) {
+func (as *AstStringer) visitDestructureStmt(d *DestructureStmt) *Completion {
+	keyword := "var"
+	if d.constant {
+		keyword = "const"
+	}
+	as.str.WriteString(fmt.Sprintf("(%s ", keyword))
+	as.pattern(d.pattern)
+	as.str.WriteString(" ")
+	d.initializer.accept(as)
+	as.str.WriteString(")")
+	return nil
+}
+
+func (as *AstStringer) visitBlockStmt(b *BlockStmt) *Completion {
 	as.str.WriteString("(block ")
 
 	for _, stmt := range b.stmts {
@@ -148,10 +336,10 @@ func (as *AstStringer) visitBlockStmt(b *BlockStmt) {
 	}
 
 	as.str.WriteString(")")
-
+	return nil
 }
 
-func (as *AstStringer) visitIfStmt(i *IfStmt) {
+func (as *AstStringer) visitIfStmt(i *IfStmt) *Completion {
 	as.str.WriteString("(if ")
 	i.cond.accept(as)
 	as.str.WriteString(" ")
@@ -161,48 +349,124 @@ func (as *AstStringer) visitIfStmt(i *IfStmt) {
 		i.or.accept(as)
 	}
 	as.str.WriteString(")")
+	return nil
 }
 
-func (as *AstStringer) visitEnvStmt(e *EnvStmt) {
+func (as *AstStringer) visitEnvStmt(e *EnvStmt) *Completion {
 	as.str.WriteString("(env)")
+	return nil
 }
 
-func (as *AstStringer) visitWhileStmt(w *WhileStmt) {
+func (as *AstStringer) visitWhileStmt(w *WhileStmt) *Completion {
 	as.str.WriteString("(while ")
 	w.cond.accept(as)
 	as.str.WriteString(" ")
 	w.body.accept(as)
+	if w.incr != nil {
+		as.str.WriteString(" ")
+		w.incr.accept(as)
+	}
 	as.str.WriteString(")")
+	return nil
 }
 
-func (as *AstStringer) visitBreakStmt(b *BreakStmt) {
+func (as *AstStringer) visitForInStmt(f *ForInStmt) *Completion {
+	as.str.WriteString(fmt.Sprintf("(for %s ", f.name.lexeme))
+	f.iterable.accept(as)
+	as.str.WriteString(" ")
+	f.body.accept(as)
+	as.str.WriteString(")")
+	return nil
+}
+
+func (as *AstStringer) visitBreakStmt(b *BreakStmt) *Completion {
 	as.str.WriteString("(break)")
+	return nil
 }
 
-func (as *AstStringer) visitFunStmt(f *FunStmt) {
-	as.str.WriteString(fmt.Sprintf("(fun %s ", f.name.lexeme))
-	if len(f.args) != 0 {
-		as.str.WriteString("(")
-		for i, arg := range f.args {
-			as.str.WriteString(arg.lexeme)
-			if i < len(f.args)-1 {
-				as.str.WriteString(" ")
-			}
-		}
-		as.str.WriteString(")")
-	}
+func (as *AstStringer) visitContinueStmt(c *ContinueStmt) *Completion {
+	as.str.WriteString("(continue)")
+	return nil
+}
+
+func (as *AstStringer) visitFunStmt(f *FunStmt) *Completion {
+	as.str.WriteString(fmt.Sprintf("(%sfun %s ", constant(f.constant), f.name.lexeme))
+	as.params(f.args)
+	as.returns(f.returns)
 	for _, stmt := range f.body {
 		stmt.accept(as)
 	}
 	as.str.WriteString(")")
+	return nil
+}
+
+func (as *AstStringer) visitReturnStmt(r *ReturnStmt) *Completion {
+	as.str.WriteString("(return")
+	if r.value != nil {
+		as.str.WriteString(" ")
+		r.value.accept(as)
+	}
+	as.str.WriteString(")")
+	return nil
+}
+
+func (as *AstStringer) visitYieldStmt(y *YieldStmt) *Completion {
+	as.str.WriteString("(yield")
+	if y.value != nil {
+		as.str.WriteString(" ")
+		y.value.accept(as)
+	}
+	as.str.WriteString(")")
+	return nil
+}
+
+func (as *AstStringer) visitClassStmt(c *ClassStmt) *Completion {
+	as.str.WriteString(fmt.Sprintf("(%sclass %s", constant(c.constant), c.name.lexeme))
+	if len(c.traits) > 0 {
+		as.str.WriteString(" (with")
+		for _, trait := range c.traits {
+			as.str.WriteString(" ")
+			trait.accept(as)
+		}
+		as.str.WriteString(")")
+	}
+	as.methods(c.methods)
+	as.str.WriteString(")")
+	return nil
 }
 
-func (as *AstStringer) visitReturnStmt(r *ReturnStmt) {
-	as.str.WriteString("(return ")
-	r.value.accept(as)
+func (as *AstStringer) visitTraitStmt(t *TraitStmt) *Completion {
+	as.str.WriteString(fmt.Sprintf("(trait %s", t.name.lexeme))
+	as.methods(t.methods)
 	as.str.WriteString(")")
+	return nil
 }
 
-func (as *AstStringer) visitClassStmt(c *ClassStmt) {
-	fmt.Printf("(class %s)", c.name.lexeme)
+func (as *AstStringer) methods(methods []Method) {
+	for _, method := range methods {
+		as.str.WriteString(" ")
+		if method.override {
+			as.str.WriteString("override ")
+		}
+		if method.static {
+			as.str.WriteString("class ")
+		}
+		switch method.kind {
+		case getterMethod:
+			as.str.WriteString("get ")
+		case setterMethod:
+			as.str.WriteString("set ")
+		}
+		as.visitFunStmt(&method.FunStmt)
+	}
+}
+
+func (as *AstStringer) resolved(expr Expr) {
+	if as.locals == nil {
+		return
+	}
+
+	if slot, ok := as.locals[expr]; ok {
+		as.str.WriteString(fmt.Sprintf("@%d:%d", slot.depth, slot.index))
+	}
 }
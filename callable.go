@@ -1,6 +1,46 @@
 package main
 
+import "fmt"
+
 type Callable interface {
-	arity() int
+	// arity returns how many arguments callable accepts,
+	// max is variadic when there is no upper limit
+	arity() (required int, max int)
 	call(i *Interpreter, args ...any) (ret any)
 }
+
+// variadic is the max arity of callables
+// accepting any number of arguments
+const variadic = -1
+
+// accepts reports whether callable can be called with n arguments.
+func accepts(callable Callable, n int) bool {
+	required, max := callable.arity()
+	return n >= required && (max == variadic || n <= max)
+}
+
+func arityError(callable Callable, got int) string {
+	required, max := callable.arity()
+	return arityMessage(stringify(callable), required, max, got)
+}
+
+// arityMessage is shared with Checker, which knows
+// only the name of the function it checks.
+func arityMessage(name string, required int, max int, got int) string {
+	switch {
+	case max == variadic:
+		return fmt.Sprintf("%s expects at least %s but got %d.", name, arguments(required), got)
+	case required == max:
+		return fmt.Sprintf("%s expects %s but got %d.", name, arguments(required), got)
+	default:
+		return fmt.Sprintf("%s expects %d to %d arguments but got %d.", name, required, max, got)
+	}
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+
+	return fmt.Sprintf("%d arguments", n)
+}
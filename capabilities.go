@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Capabilities limit what untrusted scripts can do outside of the
+// interpreter. Natives needing denied capability are still defined,
+// but calling them is a runtime error.
+type Capabilities struct {
+	read bool
+	// directories files can be read from, any when empty
+	readDirs []string
+	write    bool
+	// directories files can be written to, any when empty
+	writeDirs []string
+	env       bool
+	clock     bool
+	stdin     bool
+	exit      bool
+}
+
+func allCapabilities() Capabilities {
+	return Capabilities{read: true, write: true, env: true, clock: true, stdin: true, exit: true}
+}
+
+func noCapabilities() Capabilities {
+	return Capabilities{}
+}
+
+func (c Capabilities) readable(fsys FileSystem, path string) error {
+	if !c.read || !within(fsys, c.readDirs, path) {
+		return &fs.PathError{Op: "read", Path: path, Err: fs.ErrPermission}
+	}
+
+	return nil
+}
+
+func (c Capabilities) writable(fsys FileSystem, path string) error {
+	if !c.write || !within(fsys, c.writeDirs, path) {
+		return &fs.PathError{Op: "write", Path: path, Err: fs.ErrPermission}
+	}
+
+	return nil
+}
+
+// allow returns error for denied capability, what describes it.
+func allow(allowed bool, what string) error {
+	if !allowed {
+		return errors.New(what + " is not allowed")
+	}
+
+	return nil
+}
+
+// within reports whether path of fsys is inside one of the dirs, any
+// path is when dirs are empty. Symlinks of the host file system are
+// followed before comparing, so links inside allowed directories can't
+// point outside of them. Links are checked before the file is opened,
+// changing them in between isn't detected. Paths of other file systems
+// have nothing to do with the host ones, they are only cleaned.
+func within(fsys FileSystem, dirs []string, path string) bool {
+	if len(dirs) == 0 {
+		return true
+	}
+
+	resolve := resolvePath
+
+	if _, ok := fsys.(osFS); !ok {
+		resolve = cleanPath
+	}
+
+	path, err := resolve(path)
+
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range dirs {
+		dir, err := resolve(dir)
+
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(dir, path)
+
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maximum number of symlinks followed while resolving a path
+const maxLinks = 255
+
+// resolvePath returns absolute path with all symlinks evaluated.
+// Parts that don't exist yet, like a file about to be written,
+// are kept as they are, dangling symlinks are resolved to the
+// file writing to them would create.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return resolveLinks(path, 0)
+}
+
+// cleanPath removes "." and ".." from path without looking at any files.
+func cleanPath(path string) (string, error) {
+	return filepath.Clean(path), nil
+}
+
+func resolveLinks(path string, links int) (string, error) {
+	if links > maxLinks {
+		return "", errors.New("too many links")
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+
+	if err == nil {
+		return resolved, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	if target, err := os.Readlink(path); err == nil {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		return resolveLinks(target, links+1)
+	}
+
+	parent := filepath.Dir(path)
+
+	if parent == path {
+		return path, nil
+	}
+
+	parent, err = resolveLinks(parent, links)
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(parent, filepath.Base(path)), nil
+}
+
+// dirsFlag is a command line flag that allows all files when used
+// alone (-allow-read) or only listed directories (-allow-read=a,b).
+type dirsFlag struct {
+	allowed *bool
+	dirs    *[]string
+}
+
+func (df dirsFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (df dirsFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*df.allowed = true
+	case "false":
+		*df.allowed = false
+	default:
+		*df.allowed = true
+		*df.dirs = append(*df.dirs, strings.Split(value, ",")...)
+	}
+
+	return nil
+}
+
+func (df dirsFlag) String() string {
+	if df.dirs == nil {
+		return ""
+	}
+
+	return strings.Join(*df.dirs, ",")
+}
@@ -0,0 +1,660 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Checker is a gradual type checker running after the Resolver. It
+// infers types of expressions where it can and reports operations
+// that are certain to fail at runtime. Values of unknown type are
+// "any", which is compatible with everything, so programs without
+// annotations are checked only for obvious mistakes.
+//
+// Variable without annotation gets the type of its initializer only
+// when it's never assigned, closures can observe any later value.
+type Checker struct {
+	scopes Stack[map[string]*binding]
+	// declarations assigned anywhere in the program
+	assigned map[Token]bool
+	classes  map[string]*classMembers
+	// expected return type of checked function, nil outside of functions
+	returns *Type
+	// type of "this" in checked methods
+	this *Type
+	// first pass only collects assignments and classes
+	collecting bool
+	errors     []error
+}
+
+type binding struct {
+	decl Token
+	typ  *Type
+	// declared with annotation, assignments are checked against it
+	annotated bool
+}
+
+// classMembers are types of methods and getters class instances
+// have, fields and methods from traits are not known.
+type classMembers struct {
+	methods map[string]*Type
+	getters map[string]*Type
+}
+
+type TypeError struct {
+	token Token
+	msg   string
+}
+
+func (te *TypeError) Error() string {
+	return fmt.Sprintf("TypeError [%d][%s]: %s", te.token.line, te.token.typ, te.msg)
+}
+
+func newChecker() *Checker {
+	return &Checker{
+		assigned: map[Token]bool{},
+		classes:  map[string]*classMembers{},
+		errors:   []error{},
+	}
+}
+
+func (c *Checker) check(stmts []Stmt) error {
+	for _, collecting := range []bool{true, false} {
+		c.collecting = collecting
+		c.scopes = NewStack[map[string]*binding]()
+		c.scopes.Push(map[string]*binding{})
+		c.stmts(stmts)
+	}
+
+	return errors.Join(c.errors...)
+}
+
+func (c *Checker) error(token Token, msg string) {
+	if !c.collecting {
+		c.errors = append(c.errors, &TypeError{token, msg})
+	}
+}
+
+func (c *Checker) stmts(stmts []Stmt) {
+	for _, stmt := range stmts {
+		stmt.accept(c)
+	}
+}
+
+func (c *Checker) expr(expr Expr) *Type {
+	if expr == nil {
+		return &Type{name: "nil"}
+	}
+
+	return expr.accept(c).(*Type)
+}
+
+func (c *Checker) scoped(check func()) {
+	c.scopes.Push(map[string]*binding{})
+	check()
+	c.scopes.Pop()
+}
+
+func (c *Checker) declare(name Token, typ *Type, annotated bool) {
+	// variables assigned somewhere can hold anything
+	if !annotated && c.assigned[name] {
+		typ = anyType
+	}
+
+	c.scopes.Peek()[name.lexeme] = &binding{name, typ, annotated}
+}
+
+func (c *Checker) lookup(name string) *binding {
+	for idx := c.scopes.Size() - 1; idx >= 0; idx-- {
+		if b, ok := c.scopes.At(idx)[name]; ok {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// annotated returns type written in annotation, "any" when missing.
+func (c *Checker) annotated(typ *Annotation) *Type {
+	if typ == nil {
+		return anyType
+	}
+
+	name := typ.name.lexeme
+	_, isClass := c.classes[name]
+
+	if !isBuiltinType(name) && !isClass {
+		c.error(typ.name, fmt.Sprintf("Unknown type '%s'.", name))
+		return anyType
+	}
+
+	return &Type{name: name}
+}
+
+func (c *Checker) visitPrintStmt(p *PrintStmt) *Completion {
+	c.expr(p.val)
+	return nil
+}
+
+func (c *Checker) visitExprStmt(es *ExprStmt) *Completion {
+	c.expr(es.expr)
+	return nil
+}
+
+func (c *Checker) visitVarStmt(v *VarStmt) *Completion {
+	value := c.expr(v.initializer)
+
+	if v.typ == nil {
+		c.declare(v.name, value, false)
+		return nil
+	}
+
+	declared := c.annotated(v.typ)
+
+	if !assignable(declared, value) {
+		c.error(v.name, fmt.Sprintf("Can't assign %s to '%s' of type %s.", value, v.name.lexeme, declared))
+	}
+
+	c.declare(v.name, declared, true)
+	return nil
+}
+
+// visitDestructureStmt declares variables bound by the pattern,
+// they can hold anything.
+func (c *Checker) visitDestructureStmt(d *DestructureStmt) *Completion {
+	c.expr(d.initializer)
+	c.pattern(d.pattern)
+	return nil
+}
+
+// pattern checks classes pattern refers to and
+// declares variables it binds in the current scope.
+func (c *Checker) pattern(pattern Pattern) {
+	for _, class := range classes(pattern) {
+		c.expr(class)
+	}
+
+	for _, name := range bindings(pattern) {
+		c.declare(name, anyType, false)
+	}
+}
+
+func (c *Checker) visitBlockStmt(b *BlockStmt) *Completion {
+	c.scoped(func() { c.stmts(b.stmts) })
+	return nil
+}
+
+func (c *Checker) visitIfStmt(i *IfStmt) *Completion {
+	c.expr(i.cond)
+	c.stmts([]Stmt{i.then})
+
+	if i.or != nil {
+		c.stmts([]Stmt{i.or})
+	}
+
+	return nil
+}
+
+func (c *Checker) visitEnvStmt(e *EnvStmt) *Completion {
+	return nil
+}
+
+func (c *Checker) visitWhileStmt(w *WhileStmt) *Completion {
+	c.expr(w.cond)
+	c.stmts([]Stmt{w.body})
+
+	if w.incr != nil {
+		c.expr(w.incr)
+	}
+
+	return nil
+}
+
+func (c *Checker) visitForInStmt(f *ForInStmt) *Completion {
+	iterable := c.expr(f.iterable)
+	element := anyType
+
+	switch iterable.name {
+	case "number", "bool", "nil", "fun", "task":
+		c.error(f.keyword, fmt.Sprintf("Can't iterate over %s.", iterable))
+	case "string":
+		element = iterable
+	case "range":
+		element = &Type{name: "number"}
+	}
+
+	c.scoped(func() {
+		c.declare(f.name, element, false)
+		c.stmts([]Stmt{f.body})
+	})
+
+	return nil
+}
+
+func (c *Checker) visitBreakStmt(b *BreakStmt) *Completion {
+	return nil
+}
+
+func (c *Checker) visitContinueStmt(co *ContinueStmt) *Completion {
+	return nil
+}
+
+func (c *Checker) visitFunStmt(f *FunStmt) *Completion {
+	// declared before the body is checked for recursive calls
+	c.declare(f.name, c.signature(fmt.Sprintf("<fn %s>", f.name.lexeme), f.args, f.returns, f.generator), false)
+	c.function(f.args, f.body, f.returns, f.generator)
+	return nil
+}
+
+// signature makes type of function with params.
+func (c *Checker) signature(name string, params []Param, returns *Annotation, generator bool) *Type {
+	sig := &Signature{name: name, returns: c.annotated(returns)}
+
+	if generator {
+		sig.returns = &Type{name: "generator"}
+	}
+
+	for _, param := range params {
+		if param.rest {
+			sig.max = variadic
+			break
+		}
+
+		sig.params = append(sig.params, c.annotated(param.typ))
+		sig.max++
+
+		if param.value == nil {
+			sig.min++
+		}
+	}
+
+	return &Type{name: "fun", fn: sig}
+}
+
+func (c *Checker) function(params []Param, body []Stmt, returns *Annotation, generator bool) {
+	enclosing := c.returns
+	c.returns = c.annotated(returns)
+
+	// generators return when they are done, not values
+	if generator {
+		c.returns = nil
+	}
+
+	defer func() { c.returns = enclosing }()
+
+	c.scoped(func() {
+		for _, param := range params {
+			typ := c.annotated(param.typ)
+
+			if param.rest && param.typ == nil {
+				typ = &Type{name: "list"}
+			}
+
+			if param.value != nil {
+				if value := c.expr(param.value); !assignable(typ, value) {
+					c.error(param.name, fmt.Sprintf("Can't use %s as default value of '%s' of type %s.", value, param.name.lexeme, typ))
+				}
+			}
+
+			if param.pattern != nil {
+				c.pattern(param.pattern)
+				continue
+			}
+
+			c.declare(param.name, typ, param.typ != nil)
+		}
+
+		c.stmts(body)
+	})
+}
+
+func (c *Checker) visitReturnStmt(r *ReturnStmt) *Completion {
+	value := c.expr(r.value)
+
+	if c.returns != nil && !assignable(c.returns, value) {
+		c.error(r.keyword, fmt.Sprintf("Can't return %s from function returning %s.", value, c.returns))
+	}
+
+	return nil
+}
+
+func (c *Checker) visitYieldStmt(y *YieldStmt) *Completion {
+	c.expr(y.value)
+	return nil
+}
+
+func (c *Checker) visitClassStmt(cs *ClassStmt) *Completion {
+	instance := &Type{name: cs.name.lexeme}
+	members := &classMembers{map[string]*Type{}, map[string]*Type{}}
+	c.classes[cs.name.lexeme] = members
+
+	init := &Type{name: "fun", fn: &Signature{}}
+
+	// "init" can come from a trait
+	if len(cs.traits) > 0 {
+		init.fn.max = variadic
+	}
+
+	for _, method := range cs.methods {
+		if method.static {
+			continue
+		}
+
+		signature := c.signature(fmt.Sprintf("<fn %s>", method.name.lexeme), method.args, method.returns, method.generator)
+
+		switch {
+		case method.kind == getterMethod:
+			members.getters[method.name.lexeme] = signature.fn.returns
+		case method.kind == plainMethod:
+			members.methods[method.name.lexeme] = signature
+		}
+
+		if isInitializer(method) {
+			init = signature
+		}
+	}
+
+	// calling class runs "init" and returns instance
+	name := fmt.Sprintf("<class %s>", cs.name.lexeme)
+	class := &Type{name: "fun", fn: &Signature{name, init.fn.params, init.fn.min, init.fn.max, instance}}
+	c.declare(cs.name, class, false)
+
+	for _, trait := range cs.traits {
+		c.expr(trait)
+	}
+
+	c.methods(cs.methods, instance)
+	return nil
+}
+
+func (c *Checker) visitTraitStmt(t *TraitStmt) *Completion {
+	c.declare(t.name, anyType, false)
+	c.methods(t.methods, anyType)
+	return nil
+}
+
+func (c *Checker) methods(methods []Method, instance *Type) {
+	enclosing := c.this
+	defer func() { c.this = enclosing }()
+
+	for _, method := range methods {
+		c.this = instance
+
+		// "this" is the class in static methods
+		if method.static {
+			c.this = anyType
+		}
+
+		returns := method.returns
+
+		// initializer always returns the instance
+		if isInitializer(method) {
+			returns = &Annotation{Token{typ: NIL, lexeme: "nil"}}
+		}
+
+		c.function(method.args, method.body, returns, method.generator)
+	}
+}
+
+func (c *Checker) visitLiteral(l *Literal) any {
+	return &Type{name: typeOf(l.value)}
+}
+
+func (c *Checker) visitGrouping(g *Grouping) any {
+	return c.expr(g.expression)
+}
+
+func (c *Checker) visitUnary(u *Unary) any {
+	right := c.expr(u.right)
+
+	if u.op.typ == BANG {
+		return &Type{name: "bool"}
+	}
+
+	if !assignable(&Type{name: "number"}, right) {
+		c.error(u.op, fmt.Sprintf("Operand must be a number, got %s.", right))
+	}
+
+	return &Type{name: "number"}
+}
+
+func (c *Checker) visitBinary(b *Binary) any {
+	left := c.expr(b.left)
+	right := c.expr(b.right)
+	number := &Type{name: "number"}
+
+	switch b.op.typ {
+	case EQUAL_EQUAL, BANG_EQUAL:
+		return &Type{name: "bool"}
+	case PLUS:
+		switch {
+		case left.name == "number" && right.name == "number":
+			return number
+		case left.name == "string" || right.name == "string":
+			return &Type{name: "string"}
+		case left.name == "any" || right.name == "any":
+			return anyType
+		}
+
+		c.error(b.op, fmt.Sprintf("Operands must be numbers or strings: %s + %s.", left, right))
+		return anyType
+	}
+
+	if !assignable(number, left) || !assignable(number, right) {
+		c.error(b.op, fmt.Sprintf("Operands must be numbers: %s %s %s.", left, b.op.lexeme, right))
+	}
+
+	switch b.op.typ {
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		return &Type{name: "bool"}
+	}
+
+	return number
+}
+
+func (c *Checker) visitLogical(l *Logical) any {
+	left := c.expr(l.left)
+	right := c.expr(l.right)
+
+	if left.name == right.name {
+		return left
+	}
+
+	return anyType
+}
+
+func (c *Checker) visitVariable(v *Variable) any {
+	if b := c.lookup(v.name.lexeme); b != nil {
+		return b.typ
+	}
+
+	// natives and globals declared later
+	return anyType
+}
+
+func (c *Checker) visitAssignment(a *Assign) any {
+	value := c.expr(a.value)
+	b := c.lookup(a.variable.lexeme)
+
+	if b == nil {
+		return value
+	}
+
+	if c.collecting {
+		c.assigned[b.decl] = true
+	}
+
+	if b.annotated && !assignable(b.typ, value) {
+		c.error(a.variable, fmt.Sprintf("Can't assign %s to '%s' of type %s.", value, a.variable.lexeme, b.typ))
+	}
+
+	return value
+}
+
+// visitDestructure checks targets like assignments of unknown values.
+func (c *Checker) visitDestructure(d *Destructure) any {
+	value := c.expr(d.value)
+
+	for _, target := range d.targets {
+		if b := c.lookup(target.name.lexeme); b != nil && c.collecting {
+			c.assigned[b.decl] = true
+		}
+	}
+
+	return value
+}
+
+func (c *Checker) visitList(l *ListExpr) any {
+	for _, element := range l.elements {
+		c.expr(element)
+	}
+
+	return &Type{name: "list"}
+}
+
+func (c *Checker) visitCall(ca *Call) any {
+	callee := c.expr(ca.callee)
+	args := []*Type{}
+	spread := false
+
+	for _, arg := range ca.args {
+		_, isSpread := arg.(*Spread)
+		spread = spread || isSpread
+		args = append(args, c.expr(arg))
+	}
+
+	if callee.name != "fun" && callee.name != "any" {
+		c.error(ca.paren, fmt.Sprintf("Can only call functions and classes, got %s.", callee))
+		return anyType
+	}
+
+	sig := callee.fn
+
+	if sig == nil {
+		return anyType
+	}
+
+	// spread arguments are counted at runtime
+	if spread {
+		return sig.returns
+	}
+
+	if len(args) < sig.min || (sig.max != variadic && len(args) > sig.max) {
+		c.error(ca.paren, arityMessage(sig.name, sig.min, sig.max, len(args)))
+		return sig.returns
+	}
+
+	for idx, arg := range args {
+		if idx < len(sig.params) && !assignable(sig.params[idx], arg) {
+			c.error(ca.paren, fmt.Sprintf("Argument %d of %s must be %s, got %s.", idx+1, sig.name, sig.params[idx], arg))
+		}
+	}
+
+	return sig.returns
+}
+
+func (c *Checker) visitSpawn(s *Spawn) any {
+	c.expr(s.call)
+	return &Type{name: "task"}
+}
+
+// visitMatch checks cases in their own scopes, pattern variables
+// can hold anything. Match has the type of its cases when they agree.
+func (c *Checker) visitMatch(m *Match) any {
+	c.expr(m.value)
+
+	var typ *Type
+
+	for _, ca := range m.cases {
+		for _, pattern := range ca.patterns {
+			for _, class := range classes(pattern) {
+				c.expr(class)
+			}
+		}
+
+		c.scoped(func() {
+			for _, name := range ca.bindings {
+				c.declare(name, anyType, false)
+			}
+
+			if ca.guard != nil {
+				c.expr(ca.guard)
+			}
+
+			body := c.expr(ca.body)
+
+			if typ == nil {
+				typ = body
+			} else if typ.name != body.name {
+				typ = anyType
+			}
+		})
+	}
+
+	if typ == nil {
+		return anyType
+	}
+
+	return typ
+}
+
+func (c *Checker) visitLambda(l *Lambda) any {
+	c.function(l.args, l.body, l.returns, l.generator)
+	return c.signature("<fn>", l.args, l.returns, l.generator)
+}
+
+func (c *Checker) visitSpread(s *Spread) any {
+	list := c.expr(s.list)
+
+	if !assignable(&Type{name: "list"}, list) {
+		c.error(s.ellipsis, fmt.Sprintf("Can only spread lists, got %s.", list))
+	}
+
+	return anyType
+}
+
+func (c *Checker) visitGet(g *Get) any {
+	object := c.expr(g.obj)
+
+	switch object.name {
+	case "number", "string", "bool", "nil":
+		c.error(g.name, fmt.Sprintf("Only objects can have properties, got %s.", object))
+		return anyType
+	}
+
+	members, ok := c.classes[object.name]
+
+	if !ok {
+		return anyType
+	}
+
+	if getter, ok := members.getters[g.name.lexeme]; ok {
+		return getter
+	}
+
+	if method, ok := members.methods[g.name.lexeme]; ok {
+		return method
+	}
+
+	return anyType
+}
+
+func (c *Checker) visitSet(s *Set) any {
+	object := c.expr(s.obj)
+	value := c.expr(s.value)
+
+	switch object.name {
+	case "number", "string", "bool", "nil", "list", "map":
+		c.error(s.name, fmt.Sprintf("Only class instances have fields, got %s.", object))
+	}
+
+	return value
+}
+
+func (c *Checker) visitThis(t *This) any {
+	if c.this == nil {
+		return anyType
+	}
+
+	return c.this
+}
@@ -1,37 +1,198 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
 
+// Class is an object itself: static methods live in its metaclass
+// and are bound to the class, static fields are kept in props.
 type Class struct {
-	name string
+	name    string
+	methods map[string]*Function
+	// run on property access and assignment instead of fields
+	getters map[string]*Function
+	setters map[string]*Function
+	// nil for metaclasses
+	metaclass *Class
+	props     map[string]any
+	mu        sync.Mutex
 }
 
+func newClass(name string) *Class {
+	return &Class{
+		name:    name,
+		methods: map[string]*Function{},
+		getters: map[string]*Function{},
+		setters: map[string]*Function{},
+		props:   map[string]any{},
+	}
+}
+
+// members returns class holding methods, getters
+// and setters of object, nil for other values.
+func members(object any) *Class {
+	switch obj := object.(type) {
+	case *ClassInstance:
+		return obj.klass
+	case *Class:
+		return obj.metaclass
+	}
+
+	return nil
+}
+
+// ClassInstance can be shared between spawned
+// tasks, fields are accessed under the lock.
 type ClassInstance struct {
 	klass *Class
 	props map[string]any
+	mu    sync.Mutex
+}
+
+func newInstance(klass *Class) *ClassInstance {
+	return &ClassInstance{klass: klass, props: map[string]any{}}
 }
 
 func (c *ClassInstance) String() string {
 	return fmt.Sprintf("instance of %s", c.klass.name)
 }
 
+// get looks up fields first so they can shadow methods.
 func (c *ClassInstance) get(name string) (any, bool) {
+	c.mu.Lock()
 	val, ok := c.props[name]
-	return val, ok
+	c.mu.Unlock()
+
+	if ok {
+		return val, true
+	}
+
+	if method, ok := c.klass.methods[name]; ok {
+		return method.bind(c), true
+	}
+
+	return nil, false
 }
 
 func (c *ClassInstance) set(name string, val any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.props[name] = val
 }
 
-func (c *Class) arity() int {
-	return 0
+// fields returns sorted names of instance fields.
+func (c *ClassInstance) fields() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := []string{}
+	for name := range c.props {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+func (c *Class) arity() (int, int) {
+	if init, ok := c.methods["init"]; ok {
+		return init.arity()
+	}
+
+	return 0, 0
 }
 
 func (c *Class) call(i *Interpreter, args ...any) (ret any) {
-	return &ClassInstance{c, map[string]any{}}
+	instance := newInstance(c)
+
+	if init, ok := c.methods["init"]; ok {
+		init.bind(instance).call(i, args...)
+	}
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	return instance
+}
+
+// define adds method declared in closure to the class,
+// static methods are added to the metaclass.
+func (c *Class) define(method Method, closure *Environment) {
+	fun := newFunction(method.name, method.args, method.body, method.generator, closure)
+	fun.initializer = isInitializer(method)
+	fun.returns = method.returns
+
+	klass := c
+
+	if method.static {
+		klass = c.metaclass
+	}
+
+	switch method.kind {
+	case getterMethod:
+		klass.getters[method.name.lexeme] = fun
+	case setterMethod:
+		klass.setters[method.name.lexeme] = fun
+	default:
+		klass.methods[method.name.lexeme] = fun
+	}
+}
+
+// get looks up static fields first so they can shadow static methods.
+func (c *Class) get(name string) (any, bool) {
+	c.mu.Lock()
+	val, ok := c.props[name]
+	c.mu.Unlock()
+
+	if ok {
+		return val, true
+	}
+
+	if c.metaclass == nil {
+		return nil, false
+	}
+
+	if method, ok := c.metaclass.methods[name]; ok {
+		return method.bind(c), true
+	}
+
+	return nil, false
+}
+
+func (c *Class) set(name string, val any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.props[name] = val
 }
 
 func (c *Class) String() string {
-	return c.name
+	return fmt.Sprintf("<class %s>", c.name)
+}
+
+// Trait is a set of methods shared by unrelated classes. Methods
+// are copied into every class using the trait, so "this" is bound
+// to instances of that class.
+type Trait struct {
+	name    string
+	methods []Method
+	closure *Environment
+}
+
+func (t *Trait) String() string {
+	return fmt.Sprintf("<trait %s>", t.name)
+}
+
+// memberKey names a property method provides, getters and setters of
+// the same property conflict with each other as well as with methods.
+func memberKey(method Method) string {
+	if method.static {
+		return "class " + method.name.lexeme
+	}
+
+	return method.name.lexeme
 }
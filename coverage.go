@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Coverage counts how many times lines and branches of every
+// script run with it were executed. Resolver registers lines
+// having statements and branches before the script runs, so
+// code that never ran is reported too.
+type Coverage struct {
+	files []*fileCoverage
+	mu    sync.Mutex
+}
+
+type fileCoverage struct {
+	path   string
+	source []byte
+	// hits of lines having statements
+	lines    map[int]int64
+	branches map[any]*branch
+	// branches in the order Resolver found them
+	order []*branch
+	mu    sync.Mutex
+}
+
+// branch counts how many times each arm of "if" or logical
+// operator was taken: then and else branches, or left operand
+// deciding the result and right one being evaluated.
+type branch struct {
+	token Token
+	arms  [2]int64
+}
+
+func newCoverage() *Coverage {
+	return &Coverage{}
+}
+
+// file starts coverage of the script at path, nil coverage
+// gives nil file so nothing is counted.
+func (c *Coverage) file(path string, source []byte) *fileCoverage {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	file := &fileCoverage{
+		path:     path,
+		source:   source,
+		lines:    map[int]int64{},
+		branches: map[any]*branch{},
+	}
+	c.files = append(c.files, file)
+
+	return file
+}
+
+// coverageLine returns line statement is counted at, 0 for blocks
+// since statements inside of them are counted on their own.
+func coverageLine(stmt Stmt) int {
+	if _, ok := stmt.(*BlockStmt); ok {
+		return 0
+	}
+
+	return stmtLine(stmt)
+}
+
+// instrument registers line of the statement.
+func (f *fileCoverage) instrument(stmt Stmt) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	line := coverageLine(stmt)
+
+	if _, ok := f.lines[line]; line != 0 && !ok {
+		f.lines[line] = 0
+	}
+}
+
+// instrumentBranch registers "if" statement or logical expression.
+func (f *fileCoverage) instrumentBranch(node any, token Token) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b := &branch{token: token}
+	f.branches[node] = b
+	f.order = append(f.order, b)
+}
+
+func (f *fileCoverage) statement(stmt Stmt) {
+	line := coverageLine(stmt)
+
+	if line == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lines[line]++
+}
+
+// take counts arm of the branch registered for node.
+func (f *fileCoverage) take(node any, arm int) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if b, ok := f.branches[node]; ok {
+		b.arms[arm]++
+	}
+}
+
+func (f *fileCoverage) sortedLines() []int {
+	lines := []int{}
+	for line := range f.lines {
+		lines = append(lines, line)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// summary returns numbers of found and hit lines and branches.
+func (f *fileCoverage) summary() (lines, linesHit, branches, branchesHit int) {
+	for _, hits := range f.lines {
+		lines++
+		if hits > 0 {
+			linesHit++
+		}
+	}
+
+	for _, b := range f.order {
+		for _, taken := range b.arms {
+			branches++
+			if taken > 0 {
+				branchesHit++
+			}
+		}
+	}
+
+	return lines, linesHit, branches, branchesHit
+}
+
+// writeLcov writes coverage in the LCOV tracefile format
+// understood by genhtml and most editors.
+func (c *Coverage) writeLcov(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := bufio.NewWriter(w)
+
+	for _, f := range c.files {
+		f.mu.Lock()
+
+		fmt.Fprintf(out, "TN:\nSF:%s\n", f.path)
+
+		for block, b := range f.order {
+			for arm, taken := range b.arms {
+				// "-" tells that the condition itself never ran
+				count := "-"
+				if b.arms[0]+b.arms[1] > 0 {
+					count = fmt.Sprint(taken)
+				}
+				fmt.Fprintf(out, "BRDA:%d,%d,%d,%s\n", b.token.line, block, arm, count)
+			}
+		}
+
+		lines, linesHit, branches, branchesHit := f.summary()
+		fmt.Fprintf(out, "BRF:%d\nBRH:%d\n", branches, branchesHit)
+
+		for _, line := range f.sortedLines() {
+			fmt.Fprintf(out, "DA:%d,%d\n", line, f.lines[line])
+		}
+
+		fmt.Fprintf(out, "LF:%d\nLH:%d\nend_of_record\n", lines, linesHit)
+
+		f.mu.Unlock()
+	}
+
+	return out.Flush()
+}
+
+// writeText writes source of every script with hit counts
+// next to the lines, "#####" marks lines that never ran.
+// Lines with branches are followed by counts of their arms.
+func (c *Coverage) writeText(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := bufio.NewWriter(w)
+
+	for _, f := range c.files {
+		f.mu.Lock()
+
+		lines, linesHit, branches, branchesHit := f.summary()
+		fmt.Fprintf(out, "%s: %d/%d lines, %d/%d branches\n", f.path, linesHit, lines, branchesHit, branches)
+
+		branchesAt := map[int][]*branch{}
+		for _, b := range f.order {
+			branchesAt[b.token.line] = append(branchesAt[b.token.line], b)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(f.source))
+
+		for line := 1; scanner.Scan(); line++ {
+			count := ""
+
+			if hits, ok := f.lines[line]; ok {
+				count = fmt.Sprint(hits)
+				if hits == 0 {
+					count = "#####"
+				}
+			}
+
+			fmt.Fprintf(out, "%8s %4d| %s\n", count, line, scanner.Text())
+
+			for _, b := range branchesAt[line] {
+				fmt.Fprintf(out, "%8s %4s| %s\n", "", "", b.describe())
+			}
+		}
+
+		fmt.Fprintln(out)
+
+		f.mu.Unlock()
+	}
+
+	return out.Flush()
+}
+
+func (b *branch) describe() string {
+	if b.token.typ == IF {
+		return fmt.Sprintf("if: then %d, else %d", b.arms[0], b.arms[1])
+	}
+
+	return fmt.Sprintf("%s: left %d, right %d", b.token.lexeme, b.arms[0], b.arms[1])
+}
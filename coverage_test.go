@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	source := `fun sign(n) {
+  if (n < 0) return -1;
+  if (n > 0 or false) {
+    return 1;
+  }
+  return 0;
+}
+print sign(1);
+print sign(2);
+`
+
+	coverage := newCoverage()
+	interpreter := testInterpreter(io.Discard)
+	interpreter.coverage = coverage.file("sign.lox", []byte(source))
+
+	runScript(t, interpreter, source)
+
+	lcov := &strings.Builder{}
+	if err := coverage.writeLcov(lcov); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `TN:
+SF:sign.lox
+BRDA:2,0,0,0
+BRDA:2,0,1,2
+BRDA:3,1,0,2
+BRDA:3,1,1,0
+BRDA:3,2,0,2
+BRDA:3,2,1,0
+BRF:6
+BRH:3
+DA:1,1
+DA:2,2
+DA:3,2
+DA:4,2
+DA:6,0
+DA:8,1
+DA:9,1
+LF:7
+LH:6
+end_of_record
+`
+
+	if lcov.String() != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, lcov)
+	}
+
+	text := &strings.Builder{}
+	if err := coverage.writeText(text); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"sign.lox: 6/7 lines, 3/6 branches", "#####    6|   return 0;", "| or: left 2, right 0"} {
+		if !strings.Contains(text.String(), line) {
+			t.Errorf("expected %q in\n%s", line, text)
+		}
+	}
+}
@@ -1,19 +1,47 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
+// Environment keeps variables in slots assigned by the Resolver
+// in declaration order, so local variables are defined by appending
+// and accessed by index. Globals can't be resolved ahead of time
+// (REPL, forward references from functions) so global environment
+// additionally keeps track of names.
+//
+// Spawned tasks share environments through closures,
+// so every access is guarded by the lock. Plain mutex
+// is noticeably cheaper than RWMutex for short reads.
 type Environment struct {
-	values    map[string]any
+	values    []any
 	enclosing *Environment
+	names     map[string]int
+	// const globals, locals are checked by the Resolver
+	constants map[string]bool
+	mu        sync.Mutex
 }
 
 func newEnvironment(enclosing *Environment) *Environment {
-	return &Environment{map[string]any{}, enclosing}
+	return &Environment{enclosing: enclosing}
+}
+
+func newGlobalEnvironment() *Environment {
+	return &Environment{names: map[string]int{}, constants: map[string]bool{}}
 }
 
 func (env *Environment) get(key string) any {
-	if found, ok := env.values[key]; ok {
-		return found
+	env.mu.Lock()
+	slot, ok := env.names[key]
+	var val any
+	if ok {
+		val = env.values[slot]
+	}
+	env.mu.Unlock()
+
+	if ok {
+		return val
 	}
 
 	if env.enclosing != nil {
@@ -24,17 +52,68 @@ func (env *Environment) get(key string) any {
 }
 
 func (env *Environment) exists(key string) bool {
-	_, ok := env.values[key]
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	_, ok := env.names[key]
 	return ok
 }
 
 func (env *Environment) define(key string, val any) {
-	env.values[key] = val
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	env.put(key, val)
+}
+
+// declare defines variable from declaration statement,
+// const globals can't be redefined.
+func (env *Environment) declare(name Token, val any, constant bool) *RuntimeError {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	if env.constants[name.lexeme] {
+		return &RuntimeError{name, fmt.Sprintf("Can't redefine constant '%s'.", name.lexeme)}
+	}
+
+	if constant && env.constants != nil {
+		env.constants[name.lexeme] = true
+	}
+
+	env.put(name.lexeme, val)
+	return nil
+}
+
+// put defines variable, caller holds the lock.
+func (env *Environment) put(key string, val any) {
+	if env.names == nil {
+		env.values = append(env.values, val)
+		return
+	}
+
+	if slot, ok := env.names[key]; ok {
+		env.values[slot] = val
+		return
+	}
+
+	env.names[key] = len(env.values)
+	env.values = append(env.values, val)
 }
 
 func (env *Environment) assign(key Token, val any) *RuntimeError {
-	if env.exists(key.lexeme) {
-		env.values[key.lexeme] = val
+	env.mu.Lock()
+	slot, ok := env.names[key.lexeme]
+	constant := env.constants[key.lexeme]
+	if ok && !constant {
+		env.values[slot] = val
+	}
+	env.mu.Unlock()
+
+	if constant {
+		return &RuntimeError{key, fmt.Sprintf("Can't assign to constant '%s'.", key.lexeme)}
+	}
+
+	if ok {
 		return nil
 	}
 
@@ -45,12 +124,58 @@ func (env *Environment) assign(key Token, val any) *RuntimeError {
 	return env.enclosing.assign(key, val)
 }
 
-func (env *Environment) getAt(distance int, key string) any {
-	return env.ancestor(distance).get(key)
+// assignable returns error assign would fail with, so
+// several variables can be checked before assigning any.
+func (env *Environment) assignable(key Token) *RuntimeError {
+	env.mu.Lock()
+	_, ok := env.names[key.lexeme]
+	constant := env.constants[key.lexeme]
+	env.mu.Unlock()
+
+	if constant {
+		return &RuntimeError{key, fmt.Sprintf("Can't assign to constant '%s'.", key.lexeme)}
+	}
+
+	if ok {
+		return nil
+	}
+
+	if env.enclosing == nil {
+		return &RuntimeError{key, fmt.Sprintf("Can't assign: undefined variable '%s'.", key.lexeme)}
+	}
+
+	return env.enclosing.assignable(key)
 }
 
-func (env *Environment) assignAt(distance int, key Token, val any) {
-	env.ancestor(distance).values[key.lexeme] = val
+func (env *Environment) getAt(distance int, slot int) any {
+	target := env.ancestor(distance)
+
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	return target.values[slot]
+}
+
+func (env *Environment) assignAt(distance int, slot int, val any) {
+	target := env.ancestor(distance)
+
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	target.values[slot] = val
+}
+
+// dump shows environment for "env" statement.
+func (env *Environment) dump() string {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	enclosing := "<nil>"
+	if env.enclosing != nil {
+		enclosing = fmt.Sprintf("%p", env.enclosing)
+	}
+
+	return fmt.Sprintf("{values:%v enclosing:%s names:%v}", env.values, enclosing, env.names)
 }
 
 func (env *Environment) ancestor(distance int) *Environment {
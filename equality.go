@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+)
+
+// equals implements "==". Numbers, strings, booleans and nil are
+// compared by value, NaN is not equal to anything, itself included.
+// Everything else is compared by identity, unless instance's class
+// defines "equals(other)" method.
+func (i *Interpreter) equals(left any, right any) bool {
+	if instance, ok := left.(*ClassInstance); ok {
+		if equals, ok := instance.klass.methods["equals"]; ok && accepts(equals, 1) {
+			return isTruthy(equals.bind(instance).call(i, right))
+		}
+	}
+
+	// primitives are compared by value, NaN != NaN as IEEE requires;
+	// all other values are pointers, so they are compared by identity
+	return left == right
+}
+
+// hash returns hash consistent with equals: equal values have equal
+// hashes. Instances with custom "equals" have to define "hash" method
+// returning a number as well.
+func (i *Interpreter) hash(val any) (uint64, error) {
+	h := fnv.New64a()
+
+	switch v := val.(type) {
+	case nil:
+		h.Write([]byte{0})
+	case bool:
+		h.Write([]byte{1})
+		if v {
+			h.Write([]byte{1})
+		}
+	case float64:
+		// 0 == -0, so they need the same hash
+		if v == 0 {
+			v = 0
+		}
+		bits := math.Float64bits(v)
+		h.Write([]byte{2})
+		for shift := 0; shift < 64; shift += 8 {
+			h.Write([]byte{byte(bits >> shift)})
+		}
+	case string:
+		h.Write([]byte{3})
+		h.Write([]byte(v))
+	case *ClassInstance:
+		_, custom := v.klass.methods["equals"]
+		method, hashable := v.klass.methods["hash"]
+
+		if !custom {
+			return identityHash(v), nil
+		}
+
+		if !hashable {
+			return 0, fmt.Errorf("%s defines 'equals' but not 'hash'.", v.klass.name)
+		}
+
+		hash, ok := method.bind(v).call(i).(float64)
+
+		if !ok {
+			return 0, fmt.Errorf("%s.hash() must return a number.", v.klass.name)
+		}
+
+		return i.hash(hash)
+	default:
+		return identityHash(v), nil
+	}
+
+	return h.Sum64(), nil
+}
+
+func identityHash(val any) uint64 {
+	return uint64(reflect.ValueOf(val).Pointer())
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+func TestHashMatchesEquals(t *testing.T) {
+	i := testInterpreter(io.Discard)
+	class := newClass("Box")
+	instance := newInstance(class)
+
+	pairs := [][2]any{
+		{nil, nil},
+		{true, true},
+		{1.5, 1.5},
+		{0.0, math.Copysign(0, -1)},
+		{"lox", "lox"},
+		{instance, instance},
+		{class, class},
+	}
+
+	for _, pair := range pairs {
+		if !i.equals(pair[0], pair[1]) {
+			t.Fatalf("expected %v == %v", pair[0], pair[1])
+		}
+
+		left, err := i.hash(pair[0])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		right, _ := i.hash(pair[1])
+
+		if left != right {
+			t.Fatalf("equal values %v and %v have different hashes", pair[0], pair[1])
+		}
+	}
+
+	if i.equals(instance, newInstance(class)) {
+		t.Fatal("distinct instances should not be equal")
+	}
+
+	a, _ := i.hash("a")
+	b, _ := i.hash("b")
+
+	if a == b {
+		t.Fatal("different strings should have different hashes")
+	}
+}
+
+func TestHashRequiresHashWithEquals(t *testing.T) {
+	i := testInterpreter(io.Discard)
+	equals := newFunction(Token{lexeme: "equals"}, []Param{{name: Token{lexeme: "other"}}}, []Stmt{}, false, i.globals)
+	class := newClass("Point")
+	class.methods["equals"] = equals
+
+	if _, err := i.hash(newInstance(class)); err == nil {
+		t.Fatal("expected error for class with equals but without hash")
+	}
+}
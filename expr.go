@@ -12,6 +12,12 @@ type ExprVisitor interface {
 	visitAssignment(a *Assign) any
 	visitGet(g *Get) any
 	visitSet(s *Set) any
+	visitThis(t *This) any
+	visitSpread(s *Spread) any
+	visitSpawn(s *Spawn) any
+	visitMatch(m *Match) any
+	visitList(l *ListExpr) any
+	visitDestructure(d *Destructure) any
 }
 
 type Expr interface {
@@ -31,6 +37,9 @@ type Binary struct {
 }
 
 type Literal struct {
+	// literal itself, or keyword or operator
+	// it was made of by parser or optimizer
+	token Token
 	value any
 }
 
@@ -61,8 +70,17 @@ type Call struct {
 
 type Lambda struct {
 	name Token
-	args []Token
+	args []Param
 	body []Stmt
+	// body has "yield"
+	generator bool
+	returns   *Annotation
+}
+
+// Spread expands list into call arguments: f(...xs)
+type Spread struct {
+	ellipsis Token
+	list     Expr
 }
 
 type Get struct {
@@ -76,17 +94,64 @@ type Set struct {
 	value Expr
 }
 
-func (c *Call) expr()     {}
-func (u *Unary) expr()    {}
-func (a *Assign) expr()   {}
-func (b *Binary) expr()   {}
-func (l *Lambda) expr()   {}
-func (l *Literal) expr()  {}
-func (g *Grouping) expr() {}
-func (v *Variable) expr() {}
-func (l *Logical) expr()  {}
-func (g *Get) expr()      {}
-func (s *Set) expr()      {}
+// Spawn runs call on a new task: spawn f(x)
+type Spawn struct {
+	keyword Token
+	call    *Call
+}
+
+type This struct {
+	keyword Token
+}
+
+// ListExpr creates a list: [1, x, ...xs]
+type ListExpr struct {
+	bracket  Token
+	elements []Expr
+}
+
+// Destructure assigns variables bound by the pattern: [a, b] = [b, a]
+type Destructure struct {
+	equal   Token
+	pattern Pattern
+	targets []*Variable
+	value   Expr
+}
+
+// Match evaluates body of the first case matching the value.
+type Match struct {
+	keyword Token
+	value   Expr
+	cases   []Case
+}
+
+// Case matches when any of its patterns matches and guard
+// is truthy. Variables bound by all the patterns are declared
+// in the case scope, ones not bound by the matching pattern are nil.
+type Case struct {
+	patterns []Pattern
+	guard    Expr
+	body     Expr
+	bindings []Token
+}
+
+func (c *Call) expr()        {}
+func (u *Unary) expr()       {}
+func (a *Assign) expr()      {}
+func (b *Binary) expr()      {}
+func (l *Lambda) expr()      {}
+func (l *Literal) expr()     {}
+func (g *Grouping) expr()    {}
+func (v *Variable) expr()    {}
+func (l *Logical) expr()     {}
+func (g *Get) expr()         {}
+func (s *Set) expr()         {}
+func (t *This) expr()        {}
+func (s *Spread) expr()      {}
+func (s *Spawn) expr()       {}
+func (m *Match) expr()       {}
+func (l *ListExpr) expr()    {}
+func (d *Destructure) expr() {}
 
 func (u *Unary) accept(v ExprVisitor) any {
 	return v.visitUnary(u)
@@ -131,3 +196,27 @@ func (g *Get) accept(v ExprVisitor) any {
 func (s *Set) accept(v ExprVisitor) any {
 	return v.visitSet(s)
 }
+
+func (t *This) accept(v ExprVisitor) any {
+	return v.visitThis(t)
+}
+
+func (s *Spread) accept(v ExprVisitor) any {
+	return v.visitSpread(s)
+}
+
+func (s *Spawn) accept(v ExprVisitor) any {
+	return v.visitSpawn(s)
+}
+
+func (m *Match) accept(v ExprVisitor) any {
+	return v.visitMatch(m)
+}
+
+func (l *ListExpr) accept(v ExprVisitor) any {
+	return v.visitList(l)
+}
+
+func (d *Destructure) accept(v ExprVisitor) any {
+	return v.visitDestructure(d)
+}
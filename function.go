@@ -1,41 +1,227 @@
 package main
 
+import "fmt"
+
 type Function struct {
 	name    Token
-	args    []Token
+	args    []Param
 	body    []Stmt
 	closure *Environment
+	// "init" method always returns the instance
+	initializer bool
+	// calling generator returns Generator
+	// instead of running the body
+	generator bool
+	// checked in strict mode
+	returns *Annotation
+}
+
+// TailCall is returned from function body instead of
+// calling function in tail position, see Function.call.
+type TailCall struct {
+	fun  *Function
+	args []any
 }
 
+// call is a trampoline: calls in tail position return TailCall
+// and the called function runs in the same loop iteration after
+// the caller's one, so recursion in tail position doesn't grow
+// the stack. Return types of the callers are checked against
+// the final value.
 func (f *Function) call(i *Interpreter, args ...any) (ret any) {
+	if i.thrown != nil {
+		return nil
+	}
+
+	callers := []*Function{}
+
+	for {
+		ret = f.run(i, args)
+
+		tail, ok := ret.(*TailCall)
+
+		if i.thrown != nil {
+			return nil
+		}
+
+		if !ok {
+			break
+		}
+
+		// self recursion checks the same annotation only once
+		if f.returns != nil && (len(callers) == 0 || callers[len(callers)-1].returns != f.returns) {
+			callers = append(callers, f)
+		}
+
+		f, args = tail.fun, tail.args
+	}
+
+	for _, caller := range callers {
+		if !i.checkType(caller.name, caller.returns, ret) {
+			return nil
+		}
+	}
 
-	defer func() {
-		if err := recover(); err != nil {
-			re, ok := err.(Return)
+	return ret
+}
+
+// run executes body of the function once, returns its value
+// or TailCall to be run next. Error thrown by the body is
+// thrown again in the calling expression.
+func (f *Function) run(i *Interpreter, args []any) (ret any) {
+	if i.profiler != nil {
+		i.profiler.enter(f)
+		defer i.profiler.exit()
+	}
 
-			if !ok {
-				panic(err)
+	if i.tracer != nil {
+		i.tracer.call(f, args)
+		defer func() {
+			var err any
+			if i.thrown != nil {
+				err = i.thrown
 			}
+			i.tracer.ret(f, ret, err)
+		}()
+	}
+
+	env, ok := f.environment(i, args)
+
+	if !ok {
+		return nil
+	}
+
+	if f.generator {
+		return newGenerator(i, f, env)
+	}
+
+	completion := i.executeBlock(f.body, env)
+
+	if completion != nil && completion.kind == completeThrow {
+		i.throw(completion.value.(*RuntimeError))
+		return nil
+	}
+
+	if f.initializer {
+		return f.closure.getAt(0, 0)
+	}
+
+	if completion != nil {
+		ret = completion.value
+	}
+
+	if _, ok := ret.(*TailCall); !ok && !i.checkType(f.name, f.returns, ret) {
+		return nil
+	}
 
-			ret = re.val
+	return ret
+}
+
+// environment defines parameters for the call,
+// false when argument can't be bound.
+func (f *Function) environment(i *Interpreter, args []any) (*Environment, bool) {
+	env := newEnvironment(f.closure)
+
+	for idx, param := range f.args {
+		arg := f.argument(i, env, param, args, idx)
+
+		if i.thrown != nil || !i.checkType(param.name, param.typ, arg) {
+			return nil, false
+		}
+
+		if param.pattern == nil {
+			env.define(param.name.lexeme, arg)
+			continue
+		}
+
+		// classes in the pattern are resolved in function environment
+		parentEnv := i.env
+		i.env = env
+		bound := i.destructure(param.pattern, arg)
+		i.env = parentEnv
+
+		if i.thrown != nil {
+			return nil, false
+		}
+
+		for _, name := range bindings(param.pattern) {
+			env.define(name.lexeme, bound[name.lexeme])
 		}
-	}()
+	}
+
+	return env, true
+}
+
+// argument returns value for parameter at idx. Default values
+// are evaluated in the function environment, so they can
+// refer to the parameters before them.
+func (f *Function) argument(i *Interpreter, env *Environment, param Param, args []any, idx int) any {
+	if param.rest && idx < len(args) {
+		return newList(args[idx:]...)
+	}
+
+	if param.rest {
+		return newList()
+	}
+
+	if idx < len(args) {
+		return args[idx]
+	}
+
+	if param.value == nil {
+		return nil
+	}
 
+	parentEnv := i.env
+	i.env = env
+	value := i.evaluate(param.value)
+	i.env = parentEnv
+
+	return value
+}
+
+// bind makes method with "this" set to instance or,
+// for static methods, to the class itself.
+func (f *Function) bind(this any) *Function {
 	env := newEnvironment(f.closure)
+	env.define("this", this)
+	return &Function{f.name, f.args, f.body, env, f.initializer, f.generator, f.returns}
+}
 
-	for idx, arg := range f.args {
-		env.define(arg.lexeme, args[idx])
+func (f *Function) String() string {
+	// lambdas are named after "fun" keyword
+	if f.name.typ == FUN {
+		return "<fn>"
 	}
 
-	i.executeBlock(f.body, env)
+	return fmt.Sprintf("<fn %s>", f.name.lexeme)
+}
+
+// label names function in profiles and traces.
+func (f *Function) label() string {
+	if f.name.typ == FUN {
+		return "(fn)"
+	}
 
-	return nil
+	return f.name.lexeme
 }
 
-func (f *Function) arity() int {
-	return len(f.args)
+func (f *Function) arity() (int, int) {
+	required := 0
+
+	for _, param := range f.args {
+		if param.value == nil && !param.rest {
+			required++
+		}
+	}
+
+	if len(f.args) > 0 && f.args[len(f.args)-1].rest {
+		return required, variadic
+	}
+
+	return required, len(f.args)
 }
 
-func newFunction(name Token, args []Token, body []Stmt, env *Environment) Callable {
-	return &Function{name, args, body, env}
+func newFunction(name Token, args []Param, body []Stmt, generator bool, env *Environment) *Function {
+	return &Function{name, args, body, env, false, generator, nil}
 }
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Generator is returned by calling a function with "yield". Function
+// body runs on its own goroutine with forked interpreter and is
+// suspended on every "yield" until the next value is requested, so
+// only one of them runs at any time.
+//
+// Abandoned generator stops its goroutine when garbage collected,
+// unless the body itself keeps a reference to the generator.
+type Generator struct {
+	// empty for lambdas
+	name string
+	co   *coroutine
+	// value yielded by the body but not taken by next() yet
+	buffered bool
+	value    any
+}
+
+// coroutine is the part of Generator shared with its goroutine.
+// It must not reference Generator, otherwise finalizer never runs.
+type coroutine struct {
+	interpreter *Interpreter
+	body        []Stmt
+	env         *Environment
+	started     bool
+	// held while body runs, generator can be shared between tasks
+	running  sync.Mutex
+	finished bool
+	resume   chan struct{}
+	results  chan generatorResult
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type generatorResult struct {
+	value any
+	done  bool
+	// runtime error or internal panic of the body
+	err any
+}
+
+// unwinds generator goroutine when generator is closed
+var errGeneratorStopped = errors.New("generator stopped")
+
+func newGenerator(i *Interpreter, f *Function, env *Environment) *Generator {
+	co := &coroutine{
+		interpreter: i.fork(),
+		body:        f.body,
+		env:         env,
+		resume:      make(chan struct{}),
+		results:     make(chan generatorResult),
+		stop:        make(chan struct{}),
+	}
+	co.interpreter.coroutine = co
+
+	name := f.name.lexeme
+
+	if f.name.typ == FUN {
+		name = ""
+	}
+
+	generator := &Generator{name: name, co: co}
+	runtime.SetFinalizer(generator, func(g *Generator) { g.co.cancel() })
+
+	return generator
+}
+
+// run executes body on generator goroutine.
+func (co *coroutine) run() {
+	var thrown any
+
+	defer func() {
+		// internal panic of the body
+		err := recover()
+
+		if err == errGeneratorStopped {
+			return
+		}
+
+		if err == nil {
+			err = thrown
+		}
+
+		co.results <- generatorResult{done: true, err: err}
+	}()
+
+	if !co.wait() {
+		return
+	}
+
+	co.interpreter.profiler.idle()
+
+	if completion := co.interpreter.executeBlock(co.body, co.env); completion != nil && completion.kind == completeThrow {
+		thrown = completion.value
+	}
+}
+
+// wait blocks generator goroutine until it is resumed
+// or stopped, returns false when stopped.
+func (co *coroutine) wait() bool {
+	select {
+	case <-co.resume:
+		return true
+	case <-co.stop:
+		return false
+	}
+}
+
+// yield passes value to the caller and suspends generator goroutine.
+func (co *coroutine) yield(value any) {
+	co.results <- generatorResult{value: value}
+
+	if !co.wait() {
+		panic(errGeneratorStopped)
+	}
+
+	co.interpreter.profiler.idle()
+}
+
+func (co *coroutine) cancel() {
+	co.stopOnce.Do(func() { close(co.stop) })
+}
+
+// lock takes generator for the current operation. Generator can't
+// wait for itself, so resuming a running generator is an error.
+func (g *Generator) lock(i *Interpreter, token Token) bool {
+	if !g.co.running.TryLock() {
+		i.throw(&RuntimeError{token, "Generator is already running."})
+		return false
+	}
+
+	return true
+}
+
+// advance runs generator body until the next "yield"
+// unless there is a value buffered already.
+func (g *Generator) advance(i *Interpreter) {
+	co := g.co
+
+	if g.buffered || co.finished {
+		return
+	}
+
+	if !co.started {
+		co.started = true
+		go co.run()
+	}
+
+	co.resume <- struct{}{}
+	result := <-co.results
+
+	if result.done {
+		co.finished = true
+	}
+
+	if re, ok := result.err.(*RuntimeError); ok {
+		i.throw(re)
+		return
+	}
+
+	if result.err != nil {
+		panic(result.err)
+	}
+
+	if !result.done {
+		g.buffered, g.value = true, result.value
+	}
+}
+
+func (g *Generator) done(i *Interpreter, token Token) bool {
+	if !g.lock(i, token) {
+		return true
+	}
+	defer g.co.running.Unlock()
+
+	g.advance(i)
+
+	return !g.buffered
+}
+
+// next returns the next yielded value, nil when generator is done.
+func (g *Generator) next(i *Interpreter, token Token) any {
+	if !g.lock(i, token) {
+		return nil
+	}
+	defer g.co.running.Unlock()
+
+	g.advance(i)
+
+	value := g.value
+	g.buffered, g.value = false, nil
+
+	return value
+}
+
+// close stops suspended generator, following next() calls return nil.
+func (g *Generator) close(i *Interpreter, token Token) {
+	if !g.lock(i, token) {
+		return
+	}
+	defer g.co.running.Unlock()
+
+	g.buffered, g.value = false, nil
+	g.co.finished = true
+	g.co.cancel()
+}
+
+func (g *Generator) get(name string) (any, bool) {
+	switch name {
+	case "next":
+		return newNative("next", 0, func(i *Interpreter, args ...any) (any, error) {
+			return g.next(i, i.callSite), nil
+		}), true
+	case "done":
+		return newNative("done", 0, func(i *Interpreter, args ...any) (any, error) {
+			return g.done(i, i.callSite), nil
+		}), true
+	case "iterator":
+		return newNative("iterator", 0, func(i *Interpreter, args ...any) (any, error) {
+			return g, nil
+		}), true
+	case "close":
+		return newNative("close", 0, func(i *Interpreter, args ...any) (any, error) {
+			g.close(i, i.callSite)
+			return nil, nil
+		}), true
+	}
+
+	return nil, false
+}
+
+func (g *Generator) String() string {
+	if g.name == "" {
+		return "<generator>"
+	}
+
+	return fmt.Sprintf("<generator %s>", g.name)
+}
+
+// generatorIterator lets "for in" loop
+// drive generator without method lookups.
+type generatorIterator struct {
+	interpreter *Interpreter
+	token       Token
+	generator   *Generator
+}
+
+func (gi *generatorIterator) done() bool {
+	return gi.generator.done(gi.interpreter, gi.token)
+}
+
+func (gi *generatorIterator) next() any {
+	return gi.generator.next(gi.interpreter, gi.token)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAbandonedGeneratorsStop(t *testing.T) {
+	stdout := &strings.Builder{}
+	glox := &Glox{Interpreter: newInterpreter(newSystem(osFS{}, strings.NewReader(""), stdout, nil))}
+
+	before := runtime.NumGoroutine()
+
+	err := glox.run([]byte(`
+		fun naturals() {
+			var n = 0;
+			while (true) {
+				yield n;
+				n = n + 1;
+			}
+		}
+
+		for (var i in range(100)) {
+			var gen = naturals();
+			gen.next();
+		}
+	`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runtime.NumGoroutine() < before+100 {
+		t.Fatalf("expected suspended generators to have goroutines")
+	}
+
+	for attempt := 0; attempt < 100; attempt++ {
+		runtime.GC()
+
+		if runtime.NumGoroutine() <= before {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected generator goroutines to stop, %d still running", runtime.NumGoroutine()-before)
+}
@@ -1,17 +1,254 @@
 package main
 
-import "time"
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
 
-type ClockFun struct{}
+type NativeFun struct {
+	name string
+	// accepted number of arguments,
+	// max can be variadic
+	min int
+	max int
+	fn  func(i *Interpreter, args ...any) (any, error)
+}
+
+func newNative(name string, arity int, fn func(i *Interpreter, args ...any) (any, error)) *NativeFun {
+	return &NativeFun{name, arity, arity, fn}
+}
+
+func (nf *NativeFun) call(i *Interpreter, args ...any) any {
+	ret, err := nf.fn(i, args...)
+
+	if err != nil {
+		i.throw(&RuntimeError{i.callSite, fmt.Sprintf("%s: %s", nf.name, err)})
+		return nil
+	}
 
-func (cf *ClockFun) call(i *Interpreter, args ...any) any {
-	return time.Now().Unix()
+	return ret
 }
 
-func (cf *ClockFun) arity() int {
-	return 0
+func (nf *NativeFun) arity() (int, int) {
+	return nf.min, nf.max
 }
 
-func defineGlobals(env *Environment) {
-	env.define("clock", &ClockFun{})
+func (nf *NativeFun) String() string {
+	return fmt.Sprintf("<native fn %s>", nf.name)
+}
+
+func defineGlobals(env *Environment, sys *System) {
+	args := []any{}
+	for _, arg := range sys.args {
+		args = append(args, arg)
+	}
+
+	env.define("args", newList(args...))
+	env.define("clock", newNative("clock", 0, clock))
+	env.define("readFile", newNative("readFile", 1, readFile))
+	env.define("writeFile", newNative("writeFile", 2, writeFile))
+	env.define("appendFile", newNative("appendFile", 2, appendFile))
+	env.define("listDir", newNative("listDir", 1, listDir))
+	env.define("exists", newNative("exists", 1, exists))
+	env.define("readLine", newNative("readLine", 0, readLine))
+	env.define("getenv", newNative("getenv", 1, getenv))
+	env.define("exit", newNative("exit", 1, exit))
+	env.define("json", jsonNamespace())
+	env.define("regex", newNative("regex", 1, compileRegex))
+	env.define("str", newNative("str", 1, str))
+	env.define("format", &NativeFun{"format", 1, variadic, format})
+	env.define("range", &NativeFun{"range", 1, 3, makeRange})
+	env.define("channel", &NativeFun{"channel", 0, 1, makeChannel})
+	env.define("select", &NativeFun{"select", 1, variadic, selectChannel})
+}
+
+func clock(i *Interpreter, args ...any) (any, error) {
+	if err := allow(i.sys.caps.clock, "clock access"); err != nil {
+		return nil, err
+	}
+
+	return float64(time.Now().UnixMilli()) / 1000, nil
+}
+
+func str(i *Interpreter, args ...any) (any, error) {
+	return i.stringify(args[0]), nil
+}
+
+// format replaces each "{}" in template with the next value.
+func format(i *Interpreter, args ...any) (any, error) {
+	template, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	values := args[1:]
+	parts := strings.Split(template, "{}")
+
+	if len(parts)-1 != len(values) {
+		return nil, fmt.Errorf("template has %d placeholders but got %d values", len(parts)-1, len(values))
+	}
+
+	str := strings.Builder{}
+	for idx, part := range parts {
+		str.WriteString(part)
+		if idx < len(values) {
+			str.WriteString(i.stringify(values[idx]))
+		}
+	}
+
+	return str.String(), nil
+}
+
+func readFile(i *Interpreter, args ...any) (any, error) {
+	path, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.sys.caps.readable(i.sys.fs, path); err != nil {
+		return nil, err
+	}
+
+	content, err := fs.ReadFile(i.sys.fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(content), nil
+}
+
+func writeFile(i *Interpreter, args ...any) (any, error) {
+	path, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.sys.caps.writable(i.sys.fs, path); err != nil {
+		return nil, err
+	}
+
+	content, err := stringArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, i.sys.fs.WriteFile(path, []byte(content))
+}
+
+func appendFile(i *Interpreter, args ...any) (any, error) {
+	path, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.sys.caps.writable(i.sys.fs, path); err != nil {
+		return nil, err
+	}
+
+	content, err := stringArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, i.sys.fs.AppendFile(path, []byte(content))
+}
+
+func listDir(i *Interpreter, args ...any) (any, error) {
+	path, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.sys.caps.readable(i.sys.fs, path); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(i.sys.fs, path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []any{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return newList(names...), nil
+}
+
+func exists(i *Interpreter, args ...any) (any, error) {
+	path, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.sys.caps.readable(i.sys.fs, path); err != nil {
+		return nil, err
+	}
+
+	_, err = fs.Stat(i.sys.fs, path)
+
+	return err == nil, nil
+}
+
+func readLine(i *Interpreter, args ...any) (any, error) {
+	if err := allow(i.sys.caps.stdin, "reading stdin"); err != nil {
+		return nil, err
+	}
+
+	i.sys.stdinMu.Lock()
+	line, err := i.sys.stdin.ReadString('\n')
+	i.sys.stdinMu.Unlock()
+
+	if err == io.EOF && line == "" {
+		return nil, nil
+	}
+
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func getenv(i *Interpreter, args ...any) (any, error) {
+	if err := allow(i.sys.caps.env, "environment access"); err != nil {
+		return nil, err
+	}
+
+	name, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return i.sys.getenv(name), nil
+}
+
+func exit(i *Interpreter, args ...any) (any, error) {
+	if err := allow(i.sys.caps.exit, "exit"); err != nil {
+		return nil, err
+	}
+
+	code, ok := args[0].(float64)
+
+	if !ok {
+		return nil, fmt.Errorf("exit code must be a number, got %v", args[0])
+	}
+
+	i.sys.exit(int(code))
+
+	return nil, nil
+}
+
+func stringArg(arg any) (string, error) {
+	str, ok := arg.(string)
+
+	if !ok {
+		return "", fmt.Errorf("expected string argument, got %v", arg)
+	}
+
+	return str, nil
 }
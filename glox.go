@@ -2,28 +2,177 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 )
 
 type Glox struct {
 	Interpreter *Interpreter
+	// print one of dumpModes instead of running the source
+	dump string
+	// run Optimizer over parsed statements
+	optimize bool
+	// run Checker over resolved statements
+	check bool
+	// write pprof and text profiles of the script to these files
+	profile     string
+	profileText string
+	// write LCOV and annotated source coverage reports to these files
+	coverage     string
+	coverageText string
+	// write JSON lines trace to this file, only of traceFunc calls
+	// when it's set and no more than traceMax bytes when it's not 0
+	trace     string
+	traceFunc string
+	traceMax  int64
+	// file the trace is written to, nil for stderr
+	traceFile *os.File
 }
 
+var dumpModes = []string{"tokens", "ast", "ast-json", "resolved"}
+
 func main() {
-	glox := &Glox{newInterpreter()}
-	switch len(os.Args) {
-	case 1:
+	dump := flag.String("dump", "", "print tokens, ast, ast-json or resolved instead of running")
+	optimize := flag.Bool("O", false, "optimize the program before running")
+	strict := flag.Bool("strict", false, "check types before running and check annotated values at runtime")
+	noTCO := flag.Bool("no-tco", false, "disable tail call optimization, so every call keeps its Go stack frame")
+	profile := flag.String("profile", "", "write pprof profile of Lox functions and lines to `file`")
+	profileText := flag.String("profile-text", "", "write text summary of the profile to `file`, \"-\" for stderr")
+	coverage := flag.String("coverage", "", "write LCOV coverage report of the script or tests to `file`")
+	coverageText := flag.String("coverage-text", "", "write source annotated with coverage to `file`, \"-\" for stderr")
+	trace := flag.String("trace", "", "write JSON lines trace of statements, calls and assignments to `file`, \"-\" for stderr")
+	traceFunc := flag.String("trace-func", "", "trace only calls of the function with this `name` and everything they do")
+	traceMax := flag.Int64("trace-max", 100<<20, "stop tracing after this many `bytes`, 0 for no limit")
+	sandbox := flag.Bool("sandbox", false, "deny everything not allowed by -allow-* flags, implied by them")
+
+	caps := noCapabilities()
+	flag.Var(dirsFlag{&caps.read, &caps.readDirs}, "allow-read", "allow reading files, only in listed `dirs` when set")
+	flag.Var(dirsFlag{&caps.write, &caps.writeDirs}, "allow-write", "allow writing files, only in listed `dirs` when set")
+	flag.BoolVar(&caps.env, "allow-env", false, "allow reading environment variables")
+	flag.BoolVar(&caps.clock, "allow-clock", false, "allow reading the clock")
+	flag.BoolVar(&caps.stdin, "allow-stdin", false, "allow reading stdin")
+	flag.BoolVar(&caps.exit, "allow-exit", false, "allow exiting the process")
+
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: glox [flags] [file [args...]]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       glox [-O] [-coverage file] test [dir]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       glox check file...")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *dump != "" && !slices.Contains(dumpModes, *dump) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "allow-") {
+			*sandbox = true
+		}
+	})
+
+	if !*sandbox {
+		caps = allCapabilities()
+	}
+
+	args := flag.Args()
+
+	if len(args) > 0 && args[0] == "test" {
+		glox := &Glox{optimize: *optimize, coverage: *coverage, coverageText: *coverageText}
+		glox.runTests(args[1:])
+		return
+	}
+
+	if len(args) > 0 && args[0] == "check" {
+		checkFiles(args[1:])
+		return
+	}
+
+	if len(args) == 0 {
+		glox := &Glox{Interpreter: newInterpreter(newOsSystem(nil, caps)), dump: *dump, optimize: *optimize, check: *strict}
+		glox.Interpreter.strict = *strict
+		glox.Interpreter.tco = !*noTCO
 		glox.runPrompt()
-	case 2:
-		glox.runFile(os.Args[1])
-	default:
-		println("Usage: glox [file]")
+		return
+	}
+
+	// everything after the script name is passed to the script as "args"
+	glox := &Glox{
+		Interpreter:  newInterpreter(newOsSystem(args[1:], caps)),
+		dump:         *dump,
+		optimize:     *optimize,
+		check:        *strict,
+		profile:      *profile,
+		profileText:  *profileText,
+		coverage:     *coverage,
+		coverageText: *coverageText,
+		trace:        *trace,
+		traceFunc:    *traceFunc,
+		traceMax:     *traceMax,
+	}
+	glox.Interpreter.strict = *strict
+	glox.Interpreter.tco = !*noTCO
+	glox.runFile(args[0])
+}
+
+// glox check file...
+func checkFiles(paths []string) {
+	failed := false
+
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		glox := &Glox{Interpreter: newInterpreter(newOsSystem(nil, noCapabilities())), check: true}
+
+		if _, err := glox.compile(source); err != nil {
+			failed = true
+			fmt.Fprintf(os.Stderr, "%s:\n%s\n", path, err)
+		}
+	}
+
+	if failed {
 		os.Exit(1)
 	}
 }
 
+// glox [-O] [-coverage file] test [dir]
+func (gl *Glox) runTests(args []string) {
+	dir := "tests"
+
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	var coverage *Coverage
+	if gl.coverage != "" || gl.coverageText != "" {
+		coverage = newCoverage()
+	}
+
+	err := runGoldenDir(dir, os.Stdout, gl.optimize, coverage)
+
+	if err := gl.writeCoverage(coverage); err != nil {
+		log.Fatal(err)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
 func (gl *Glox) runPrompt() {
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Split(bufio.ScanLines)
@@ -35,7 +184,9 @@ func (gl *Glox) runPrompt() {
 			}
 		}()
 
-		gl.run(line)
+		if err := gl.run(line); err != nil {
+			log.Println(err)
+		}
 	}
 
 	for {
@@ -54,33 +205,238 @@ func (gl *Glox) runFile(path string) {
 		log.Fatal(err)
 	}
 
-	gl.run(file)
+	if gl.profile != "" || gl.profileText != "" {
+		gl.Interpreter.profiler = newProfiler(newProfile(path))
+	}
+
+	var coverage *Coverage
+	if gl.coverage != "" || gl.coverageText != "" {
+		coverage = newCoverage()
+		gl.Interpreter.coverage = coverage.file(path, file)
+	}
+
+	if gl.trace != "" {
+		out := os.Stderr
+
+		if gl.trace != "-" {
+			if gl.traceFile, err = os.Create(gl.trace); err != nil {
+				log.Fatal(err)
+			}
+			out = gl.traceFile
+		}
+
+		gl.Interpreter.tracer = newTracer(newTrace(out, gl.traceFunc, gl.traceMax))
+	}
+
+	// scripts calling "exit" get their reports written too
+	exit := gl.Interpreter.sys.exit
+	gl.Interpreter.sys.exit = func(code int) {
+		if err := gl.writeReports(coverage); err != nil {
+			log.Println(err)
+		}
+		exit(code)
+	}
+
+	err = gl.run(file)
+
+	if err := gl.writeReports(coverage); err != nil {
+		log.Fatal(err)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeReports writes profiles and coverage asked for
+// by flags and the rest of the trace.
+func (gl *Glox) writeReports(coverage *Coverage) error {
+	if err := gl.closeTrace(); err != nil {
+		return err
+	}
+
+	if err := gl.writeProfiles(); err != nil {
+		return err
+	}
+
+	return gl.writeCoverage(coverage)
 }
 
-func (gl *Glox) run(source []byte) {
+// closeTrace flushes the trace and closes its file.
+func (gl *Glox) closeTrace() error {
+	if gl.Interpreter.tracer == nil {
+		return nil
+	}
+
+	if err := gl.Interpreter.tracer.trace.flush(); err != nil {
+		return err
+	}
+
+	if gl.traceFile == nil {
+		return nil
+	}
+
+	file := gl.traceFile
+	gl.traceFile = nil
+
+	return file.Close()
+}
+
+func (gl *Glox) writeProfiles() error {
+	if gl.Interpreter.profiler == nil {
+		return nil
+	}
+
+	gl.Interpreter.profiler.flush()
+	profile := gl.Interpreter.profiler.profile
+
+	if err := writeReport(gl.profile, profile.writePprof); err != nil {
+		return err
+	}
+
+	return writeReport(gl.profileText, profile.writeText)
+}
+
+func (gl *Glox) writeCoverage(coverage *Coverage) error {
+	if coverage == nil {
+		return nil
+	}
+
+	if err := writeReport(gl.coverage, coverage.writeLcov); err != nil {
+		return err
+	}
+
+	return writeReport(gl.coverageText, coverage.writeText)
+}
+
+// writeReport writes report to the file at path, "-" writes
+// to stderr and empty path means report wasn't asked for.
+func writeReport(path string, write func(io.Writer) error) error {
+	if path == "" {
+		return nil
+	}
+
+	if path == "-" {
+		return write(os.Stderr)
+	}
+
+	var buf bytes.Buffer
+
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func (gl *Glox) run(source []byte) error {
+	if gl.dump != "" {
+		return gl.dumpSource(source)
+	}
+
+	stmts, err := gl.compile(source)
+
+	if err != nil {
+		return err
+	}
+
+	return gl.Interpreter.interpret(stmts)
+}
+
+func (gl *Glox) dumpSource(source []byte) error {
+	out := gl.Interpreter.sys.stdout
+
+	if gl.dump == "tokens" {
+		tokens, err := newScanner(source).scan()
+
+		for _, token := range tokens {
+			fmt.Fprintf(out, "%d:%d %s %q\n", token.line, token.column, token.typ, token.lexeme)
+		}
+
+		return err
+	}
+
+	if gl.dump == "resolved" {
+		stmts, err := gl.compile(source)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(out, AstStringer{stmts: stmts, locals: gl.Interpreter.locals})
+		return nil
+	}
+
+	tokens, err := newScanner(source).scan()
+
+	if err != nil {
+		return err
+	}
+
+	stmts, err := newParser(tokens).parse()
+
+	if err != nil {
+		return err
+	}
+
+	if gl.optimize {
+		stmts = newOptimizer().optimize(stmts)
+	}
+
+	if gl.dump == "ast" {
+		fmt.Fprintln(out, AstStringer{stmts: stmts})
+		return nil
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(&AstJSON{stmts: stmts})
+}
+
+// compile scans, parses and resolves source
+// so it is ready to be interpreted.
+func (gl *Glox) compile(source []byte) ([]Stmt, error) {
 	tokens, err := newScanner(source).scan()
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	stmts, parseErrs := newParser(tokens).parse()
 
 	if parseErrs != nil {
-		panic(parseErrs)
+		return nil, parseErrs
 	}
 
-	fmt.Println(AstStringer{stmts: stmts})
+	if gl.optimize {
+		// optimizer drops dead code, so it is resolved into
+		// throwaway maps first to report errors inside of it
+		scratch := &Interpreter{locals: map[Expr]Slot{}, tails: map[*ReturnStmt]bool{}}
+
+		if resolveErrs := newResolver(scratch).resolveStmts(stmts...); resolveErrs != nil {
+			return nil, resolveErrs
+		}
+
+		stmts = newOptimizer().optimize(stmts)
+	}
+
+	// running tasks share locals of the interpreter they were
+	// forked from, so new code is resolved into a copy
+	gl.Interpreter.locals = maps.Clone(gl.Interpreter.locals)
+	gl.Interpreter.tails = maps.Clone(gl.Interpreter.tails)
 
 	resolveErrs := newResolver(gl.Interpreter).resolveStmts(stmts...)
 
 	if resolveErrs != nil {
-		panic(resolveErrs)
+		return nil, resolveErrs
 	}
 
-	interpreterErrs := gl.Interpreter.interpret(stmts)
-
-	if interpreterErrs != nil {
-		panic(interpreterErrs)
+	if gl.check {
+		if typeErrs := newChecker().check(stmts); typeErrs != nil {
+			return nil, typeErrs
+		}
 	}
+
+	return stmts, nil
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// testInterpreter has empty stdin and prints to out.
+func testInterpreter(out io.Writer) *Interpreter {
+	return newInterpreter(newSystem(osFS{}, strings.NewReader(""), out, nil))
+}
+
+// compileScript prepares source for the interpreter
+// the same way glox does and fails on any error.
+func compileScript(t testing.TB, interpreter *Interpreter, source string) []Stmt {
+	t.Helper()
+
+	stmts, err := (&Glox{Interpreter: interpreter}).compile([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stmts
+}
+
+// runScript compiles and interprets source, failing on any error.
+func runScript(t testing.TB, interpreter *Interpreter, source string) {
+	t.Helper()
+
+	if err := interpreter.interpret(compileScript(t, interpreter, source)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Golden tests are plain lox scripts annotated with comments:
+//
+//	print 1 + 2; // expect: 3
+//	print clock(); // expect matches: ^\d+
+//	1 + nil; // expect runtime error: Operands must be numbers or strings
+//	print; // error: Expect expression
+//	// error at line 10: Unclosed block
+//
+// Every script is run with a fresh interpreter and its output
+// and errors are compared with annotations. Scripts starting
+// with "// strict" line are type checked and run in strict mode.
+
+type expectKind int
+
+const (
+	expectOutput expectKind = iota
+	expectMatch
+	expectRuntimeError
+	expectCompileError
+)
+
+type expectation struct {
+	kind    expectKind
+	line    int
+	text    string
+	pattern *regexp.Regexp
+}
+
+var (
+	expectOutputRe  = regexp.MustCompile(`//\s*expect:\s?(.*)$`)
+	expectMatchRe   = regexp.MustCompile(`//\s*expect matches:\s?(.*)$`)
+	expectRuntimeRe = regexp.MustCompile(`//\s*expect runtime error:\s?(.*)$`)
+	expectCompileRe = regexp.MustCompile(`//\s*error(?: at line (\d+))?(?::\s?(.*))?$`)
+)
+
+func parseExpectations(source []byte) ([]expectation, error) {
+	expectations := []expectation{}
+	scanner := bufio.NewScanner(bytes.NewReader(source))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		if match := expectOutputRe.FindStringSubmatch(text); match != nil {
+			expectations = append(expectations, expectation{expectOutput, line, match[1], nil})
+			continue
+		}
+
+		if match := expectMatchRe.FindStringSubmatch(text); match != nil {
+			pattern, err := regexp.Compile(match[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			expectations = append(expectations, expectation{expectMatch, line, match[1], pattern})
+			continue
+		}
+
+		if match := expectRuntimeRe.FindStringSubmatch(text); match != nil {
+			expectations = append(expectations, expectation{expectRuntimeError, line, match[1], nil})
+			continue
+		}
+
+		if match := expectCompileRe.FindStringSubmatch(text); match != nil {
+			errLine := line
+			if match[1] != "" {
+				errLine, _ = strconv.Atoi(match[1])
+			}
+			expectations = append(expectations, expectation{expectCompileError, errLine, match[2], nil})
+		}
+	}
+
+	return expectations, scanner.Err()
+}
+
+// runGolden runs a single script and returns the list of
+// mismatches with its annotations, coverage may be nil.
+func runGolden(source []byte, optimize bool, coverage *fileCoverage) (failures []string) {
+	expectations, err := parseExpectations(source)
+
+	if err != nil {
+		return []string{fmt.Sprintf("invalid expectation: %s", err)}
+	}
+
+	stdout := &strings.Builder{}
+	strict := bytes.HasPrefix(source, []byte("// strict\n"))
+	glox := &Glox{
+		Interpreter: newInterpreter(newSystem(osFS{}, strings.NewReader(""), stdout, nil)),
+		optimize:    optimize,
+		check:       strict,
+	}
+	glox.Interpreter.strict = strict
+	glox.Interpreter.coverage = coverage
+
+	defer func() {
+		if err := recover(); err != nil {
+			failures = append(failures, fmt.Sprintf("interpreter crashed: %v", err))
+		}
+	}()
+
+	stmts, compileErr := glox.compile(source)
+
+	var runtimeErr error
+
+	if compileErr == nil {
+		runtimeErr = glox.Interpreter.interpret(stmts)
+	}
+
+	failures = append(failures, checkOutput(expectations, stdout.String())...)
+	failures = append(failures, checkErrors(expectations, compileErr, runtimeErr)...)
+
+	return failures
+}
+
+func checkOutput(expectations []expectation, stdout string) []string {
+	failures := []string{}
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+
+	if stdout == "" {
+		lines = []string{}
+	}
+
+	outputs := []expectation{}
+	for _, exp := range expectations {
+		if exp.kind == expectOutput || exp.kind == expectMatch {
+			outputs = append(outputs, exp)
+		}
+	}
+
+	for idx, exp := range outputs {
+		if idx >= len(lines) {
+			failures = append(failures, fmt.Sprintf("line %d: missing output, expected %q", exp.line, exp.text))
+			continue
+		}
+
+		if exp.kind == expectOutput && lines[idx] != exp.text {
+			failures = append(failures, fmt.Sprintf("line %d: expected %q, got %q", exp.line, exp.text, lines[idx]))
+		}
+
+		if exp.kind == expectMatch && !exp.pattern.MatchString(lines[idx]) {
+			failures = append(failures, fmt.Sprintf("line %d: expected match of /%s/, got %q", exp.line, exp.text, lines[idx]))
+		}
+	}
+
+	for _, line := range lines[min(len(outputs), len(lines)):] {
+		failures = append(failures, fmt.Sprintf("unexpected output %q", line))
+	}
+
+	return failures
+}
+
+func checkErrors(expectations []expectation, compileErr error, runtimeErr error) []string {
+	failures := []string{}
+	actual := append(flattenErrors(compileErr), flattenErrors(runtimeErr)...)
+
+	for _, exp := range expectations {
+		if exp.kind != expectCompileError && exp.kind != expectRuntimeError {
+			continue
+		}
+
+		idx := -1
+		for i, err := range actual {
+			line, msg, isRuntime := describeError(err)
+			if line == exp.line && isRuntime == (exp.kind == expectRuntimeError) && strings.Contains(msg, exp.text) {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			kind := "error"
+			if exp.kind == expectRuntimeError {
+				kind = "runtime error"
+			}
+			failures = append(failures, fmt.Sprintf("line %d: missing %s %q", exp.line, kind, exp.text))
+			continue
+		}
+
+		actual = append(actual[:idx], actual[idx+1:]...)
+	}
+
+	for _, err := range actual {
+		failures = append(failures, fmt.Sprintf("unexpected error: %s", err))
+	}
+
+	return failures
+}
+
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+
+	if !ok {
+		return []error{err}
+	}
+
+	flat := []error{}
+	for _, err := range joined.Unwrap() {
+		flat = append(flat, flattenErrors(err)...)
+	}
+
+	return flat
+}
+
+func describeError(err error) (line int, msg string, isRuntime bool) {
+	switch err := err.(type) {
+	case *ScanError:
+		return err.line, err.message, false
+	case *ParseError:
+		return err.token.line, err.message, false
+	case *ResolveError:
+		return err.token.line, err.msg, false
+	case *TypeError:
+		return err.token.line, err.msg, false
+	case *RuntimeError:
+		return err.token.line, err.msg, true
+	}
+
+	return 0, err.Error(), false
+}
+
+// runGoldenDir runs every .lox file under dir and writes a report
+// to out, coverage of every file is collected when it's not nil.
+func runGoldenDir(dir string, out io.Writer, optimize bool, coverage *Coverage) error {
+	passed, failed := 0, 0
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".lox" {
+			return err
+		}
+
+		source, err := fs.ReadFile(osFS{}, path)
+
+		if err != nil {
+			return err
+		}
+
+		failures := runGolden(source, optimize, coverage.file(path, source))
+
+		if len(failures) == 0 {
+			passed++
+			fmt.Fprintf(out, "PASS %s\n", path)
+			return nil
+		}
+
+		failed++
+		fmt.Fprintf(out, "FAIL %s\n", path)
+		for _, failure := range failures {
+			fmt.Fprintf(out, "    %s\n", failure)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\n%d passed, %d failed\n", passed, failed)
+
+	if failed > 0 {
+		return errors.New("some tests failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestGolden(t *testing.T) {
+	testGolden(t, false)
+}
+
+// optimized program should behave exactly the same
+func TestGoldenOptimized(t *testing.T) {
+	testGolden(t, true)
+}
+
+func testGolden(t *testing.T, optimize bool) {
+	files, err := filepath.Glob("tests/*.lox")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := fs.ReadFile(osFS{}, file)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, failure := range runGolden(source, optimize, nil) {
+				t.Error(failure)
+			}
+		})
+	}
+}
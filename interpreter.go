@@ -3,17 +3,43 @@ package main
 import (
 	"errors"
 	"fmt"
-	"log"
 	"reflect"
 	"slices"
 )
 
 type Interpreter struct {
-	env     *Environment
-	globals *Environment
-	locals  map[Expr]int
-	errors  []error
-	brk     bool
+	env      *Environment
+	globals  *Environment
+	locals   map[Expr]Slot
+	errors   []error
+	sys      *System
+	callSite Token
+	// runtime error raised by the current statement,
+	// it becomes throw completion when statement ends
+	thrown *RuntimeError
+	// set when interpreter runs generator body
+	coroutine *coroutine
+	// shared by forks running tasks
+	scheduler *scheduler
+	// check values against type annotations
+	strict bool
+	// returns of calls in tail position found by the Resolver,
+	// they are run by the trampoline in Function.call when tco is on
+	tails map[*ReturnStmt]bool
+	tco   bool
+	// nil unless the program is profiled
+	profiler *profiler
+	// nil unless coverage of the program is collected
+	coverage *fileCoverage
+	// nil unless the program is traced
+	tracer *tracer
+}
+
+// Slot is where resolved local variable lives: how many
+// environments up the chain and index in that environment.
+type Slot struct {
+	depth int
+	index int
 }
 
 type RuntimeError struct {
@@ -21,52 +47,137 @@ type RuntimeError struct {
 	msg   string
 }
 
-type Return struct {
-	val any
-}
+type CompletionKind int
+
+const (
+	completeReturn CompletionKind = iota
+	completeBreak
+	completeContinue
+	completeThrow
+)
+
+// Completion tells that statement finished abruptly and enclosing
+// statements should stop executing until somebody handles it:
+// loops handle break and continue, function calls handle return
+// and pass thrown runtime error on to the calling expression,
+// interpret collects it. Statement that finished normally returns nil.
+//
+// Expressions return values only, so runtime error raised inside
+// of them is kept in Interpreter.thrown, the rest of the expression
+// is skipped, and execute turns it into completion.
+type Completion struct {
+	kind  CompletionKind
+	value any
+}
+
+var (
+	breakCompletion    = &Completion{completeBreak, nil}
+	continueCompletion = &Completion{completeContinue, nil}
+)
 
 func (re *RuntimeError) Error() string {
 	return fmt.Sprintf("RuntimeError [%d][%s] Error: %s", re.token.line, re.token.typ, re.msg)
 }
 
-func newInterpreter() *Interpreter {
+// newInterpreter makes interpreter talking to the outside
+// world through sys, only in ways sys.caps allow.
+func newInterpreter(sys *System) *Interpreter {
+
+	globals := newGlobalEnvironment()
 
-	globals := newEnvironment(nil)
+	defineGlobals(globals, sys)
 
-	defineGlobals(globals)
+	return &Interpreter{
+		env:       globals,
+		globals:   globals,
+		locals:    map[Expr]Slot{},
+		errors:    []error{},
+		sys:       sys,
+		tails:     map[*ReturnStmt]bool{},
+		tco:       true,
+		scheduler: newScheduler(),
+	}
+}
 
+// fork makes interpreter sharing globals and resolved
+// variables to run code on another goroutine.
+func (i *Interpreter) fork() *Interpreter {
 	return &Interpreter{
-		env:     globals,
-		globals: globals,
-		locals:  map[Expr]int{},
-		errors:  []error{},
-		brk:     false,
+		env:       i.globals,
+		globals:   i.globals,
+		locals:    i.locals,
+		errors:    []error{},
+		sys:       i.sys,
+		strict:    i.strict,
+		tails:     i.tails,
+		tco:       i.tco,
+		profiler:  i.profiler.fork(),
+		coverage:  i.coverage,
+		tracer:    i.tracer.fork(),
+		scheduler: i.scheduler,
 	}
 }
 
-func (i *Interpreter) interpret(stmts []Stmt) error {
+func (i *Interpreter) interpret(stmts []Stmt) (err error) {
+	defer func() { err = errors.Join(i.errors...) }()
 	defer i.recover()
 
 	i.errors = []error{}
+	// time spent compiling isn't charged to the script
+	i.profiler.idle()
 
 	for _, stmt := range stmts {
-		stmt.accept(i)
+		if completion := i.execute(stmt); completion != nil && completion.kind == completeThrow {
+			i.errors = append(i.errors, completion.value.(*RuntimeError))
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// execute runs statement and lets the profiler,
+// coverage and tracer know about it.
+func (i *Interpreter) execute(stmt Stmt) *Completion {
+	if i.profiler != nil {
+		i.profiler.statement(stmt)
+	}
+
+	if i.coverage != nil {
+		i.coverage.statement(stmt)
+	}
+
+	if i.tracer != nil {
+		i.tracer.statement(stmt)
+	}
+
+	completion := stmt.accept(i)
+
+	if i.thrown != nil {
+		completion = &Completion{completeThrow, i.thrown}
+		i.thrown = nil
 	}
 
-	return errors.Join(i.errors...)
+	return completion
 }
 
+// recover doesn't let bugs in the interpreter crash the host.
 func (i *Interpreter) recover() {
 	if err := recover(); err != nil {
-		_, ok := err.(*RuntimeError)
-
-		if !ok {
-			panic(err)
-		}
+		// statements don't restore environment when unwinding
+		i.env = i.globals
+		i.thrown = nil
+		i.errors = append(i.errors, fmt.Errorf("internal error: %v", err))
 	}
 }
 
+// evaluate returns nil without evaluating
+// anything once runtime error is thrown.
 func (i *Interpreter) evaluate(e Expr) any {
+	if i.thrown != nil {
+		return nil
+	}
+
 	return e.accept(i)
 }
 
@@ -74,43 +185,66 @@ func (i *Interpreter) visitBinary(b *Binary) any {
 	left := i.evaluate(b.left)
 	right := i.evaluate(b.right)
 
+	if i.thrown != nil {
+		return nil
+	}
+
 	switch b.op.typ {
 	case MINUS:
-		i.checkIfFloats(b.op, left, right)
+		if !i.checkIfFloats(b.op, left, right) {
+			return nil
+		}
 		return left.(float64) - right.(float64)
 	case SLASH:
-		i.checkIfFloats(b.op, left, right)
+		if !i.checkIfFloats(b.op, left, right) {
+			return nil
+		}
 		return left.(float64) / right.(float64)
 	case STAR:
-		i.checkIfFloats(b.op, left, right)
+		if !i.checkIfFloats(b.op, left, right) {
+			return nil
+		}
 		return left.(float64) * right.(float64)
 	case GREATER:
-		i.checkIfFloats(b.op, left, right)
+		if !i.checkIfFloats(b.op, left, right) {
+			return nil
+		}
 		return left.(float64) > right.(float64)
 	case LESS:
-		i.checkIfFloats(b.op, left, right)
+		if !i.checkIfFloats(b.op, left, right) {
+			return nil
+		}
 		return left.(float64) < right.(float64)
 	case GREATER_EQUAL:
-		i.checkIfFloats(b.op, left, right)
+		if !i.checkIfFloats(b.op, left, right) {
+			return nil
+		}
 		return left.(float64) >= right.(float64)
 	case LESS_EQUAL:
-		i.checkIfFloats(b.op, left, right)
+		if !i.checkIfFloats(b.op, left, right) {
+			return nil
+		}
 		return left.(float64) <= right.(float64)
 	case BANG_EQUAL:
-		return !reflect.DeepEqual(left, right)
+		return !i.equals(left, right)
 	case EQUAL_EQUAL:
-		return reflect.DeepEqual(left, right)
+		return i.equals(left, right)
 	case PLUS:
 		if isFloats(left, right) {
 			return left.(float64) + right.(float64)
 		}
 
-		if isStrings(left, right) {
-			return left.(string) + right.(string)
+		// string concatenation converts the other operand
+		if _, ok := left.(string); ok {
+			return left.(string) + i.stringify(right)
+		}
+
+		if _, ok := right.(string); ok {
+			return i.stringify(left) + right.(string)
 		}
 	}
 
-	i.panic(&RuntimeError{b.op, fmt.Sprintf("Operands must be numbers or strings: %v %s %v", left, b.op.lexeme, right)})
+	i.throw(&RuntimeError{b.op, fmt.Sprintf("Operands must be numbers or strings: %s %s %s", stringify(left), b.op.lexeme, stringify(right))})
 
 	return nil
 }
@@ -126,9 +260,15 @@ func (i *Interpreter) visitGrouping(g *Grouping) any {
 func (i *Interpreter) visitUnary(u *Unary) any {
 	val := i.evaluate(u.right)
 
+	if i.thrown != nil {
+		return nil
+	}
+
 	switch u.op.typ {
 	case MINUS:
-		i.checkIfFloat(u.op, val)
+		if !i.checkIfFloat(u.op, val) {
+			return nil
+		}
 		return -val.(float64)
 	case BANG:
 		return !isTruthy(val)
@@ -143,31 +283,85 @@ func (i *Interpreter) visitVariable(v *Variable) any {
 
 func (i *Interpreter) visitAssignment(a *Assign) any {
 	val := i.evaluate(a.value)
-	distance, isLocal := i.locals[a]
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	i.assign(a, a.variable, val)
+	return val
+}
+
+// assign sets variable resolved for expr.
+func (i *Interpreter) assign(expr Expr, name Token, val any) {
+	if i.tracer != nil {
+		i.tracer.assign("assign", name, val)
+	}
+
+	slot, isLocal := i.locals[expr]
 
 	if isLocal {
-		i.env.assignAt(distance, a.variable, val)
-		return val
+		i.env.assignAt(slot.depth, slot.index, val)
+		return
 	}
 
-	err := i.globals.assign(a.variable, val)
+	err := i.globals.assign(name, val)
 	if err != nil {
-		i.panic(err)
+		i.throw(err)
+	}
+}
+
+// visitDestructure evaluates the value before assigning
+// anything, so [a, b] = [b, a] swaps variables. Globals are
+// checked first, so nothing is assigned when one of them is
+// constant, locals are checked by the Resolver.
+func (i *Interpreter) visitDestructure(d *Destructure) any {
+	val := i.evaluate(d.value)
+	bound := i.destructure(d.pattern, val)
+
+	if i.thrown != nil {
+		return nil
 	}
+
+	for _, target := range d.targets {
+		if _, isLocal := i.locals[target]; isLocal {
+			continue
+		}
+
+		if err := i.globals.assignable(target.name); err != nil {
+			i.throw(err)
+			return nil
+		}
+	}
+
+	for _, target := range d.targets {
+		i.assign(target, target.name, bound[target.name.lexeme])
+	}
+
 	return val
 }
 
+func (i *Interpreter) visitList(l *ListExpr) any {
+	return newList(i.arguments(l.elements)...)
+}
+
 func (i *Interpreter) visitLogical(lo *Logical) any {
 
 	left := i.evaluate(lo.left)
 
+	if i.thrown != nil {
+		return nil
+	}
+
 	shortOr := lo.operator.typ == OR && isTruthy(left)
 	shortAnd := lo.operator.typ == AND && !isTruthy(left)
 
 	if shortOr || shortAnd {
+		i.coverage.take(lo, 0)
 		return left
 	}
 
+	i.coverage.take(lo, 1)
 	return i.evaluate(lo.right)
 }
 
@@ -175,46 +369,159 @@ func (i *Interpreter) visitCall(c *Call) any {
 
 	callee := i.evaluate(c.callee)
 
+	return i.call(c.paren, callee, i.arguments(c.args)...)
+}
+
+func (i *Interpreter) arguments(exprs []Expr) []any {
 	args := []any{}
 
-	for _, arg := range c.args {
+	for _, arg := range exprs {
+		if _, ok := arg.(*Spread); ok {
+			list, ok := i.evaluate(arg).(*List)
+
+			if !ok {
+				return args
+			}
+
+			args = append(args, list.items()...)
+			continue
+		}
+
 		args = append(args, i.evaluate(arg))
 	}
 
+	return args
+}
+
+// callable checks that callee can be called with n arguments.
+func (i *Interpreter) callable(token Token, callee any, n int) Callable {
 	callable, ok := callee.(Callable)
 
 	if !ok {
-		i.panic(&RuntimeError{c.paren, "Can only call function and classes."})
+		i.throw(&RuntimeError{token, "Can only call function and classes."})
+		return nil
+	}
+
+	if !accepts(callable, n) {
+		i.throw(&RuntimeError{token, arityError(callable, n)})
+		return nil
+	}
+
+	return callable
+}
+
+// call calls callee with arguments, errors
+// are reported at token position.
+func (i *Interpreter) call(token Token, callee any, args ...any) any {
+	if i.thrown != nil {
+		return nil
+	}
+
+	callable := i.callable(token, callee, len(args))
+
+	if callable == nil {
+		return nil
 	}
 
-	if callable.arity() != len(args) {
-		i.panic(&RuntimeError{
-			c.paren,
-			fmt.Sprintf(
-				"Expected %d arguments  but got %d",
-				callable.arity(),
-				len(args),
-			),
-		})
+	// natives report errors at the call site
+	parentSite := i.callSite
+	i.callSite = token
+	ret := callable.call(i, args...)
+	i.callSite = parentSite
+
+	return ret
+}
+
+// visitSpawn checks the call and runs it on a new
+// goroutine with forked interpreter.
+func (i *Interpreter) visitSpawn(s *Spawn) any {
+	callee := i.evaluate(s.call.callee)
+	args := i.arguments(s.call.args)
+
+	if i.thrown != nil || i.callable(s.call.paren, callee, len(args)) == nil {
+		return nil
 	}
 
-	return callable.call(i, args...)
+	fork := i.fork()
+	task := newTask(i.scheduler)
+
+	go task.run(func() (any, *RuntimeError) {
+		ret := fork.call(s.call.paren, callee, args...)
+		return ret, fork.thrown
+	})
+
+	return task
+}
+
+// visitMatch evaluates body of the first matching case in
+// a new environment holding variables bound by the pattern.
+func (i *Interpreter) visitMatch(m *Match) any {
+	value := i.evaluate(m.value)
+
+	for _, c := range m.cases {
+		for _, pattern := range c.patterns {
+			bound := map[string]any{}
+
+			if i.thrown != nil {
+				return nil
+			}
+
+			if !i.matches(pattern, value, bound) {
+				continue
+			}
+
+			env := newEnvironment(i.env)
+			for _, name := range c.bindings {
+				env.define(name.lexeme, bound[name.lexeme])
+			}
+
+			parentEnv := i.env
+			i.env = env
+
+			if c.guard == nil || isTruthy(i.evaluate(c.guard)) {
+				result := i.evaluate(c.body)
+				i.env = parentEnv
+				return result
+			}
+
+			i.env = parentEnv
+		}
+	}
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	i.throw(&RuntimeError{m.keyword, fmt.Sprintf("No case matches %s.", i.stringify(value))})
+
+	return nil
 }
 
 func (i *Interpreter) visitGet(g *Get) any {
 
 	object := i.evaluate(g.obj)
 
-	instance, ok := object.(*ClassInstance)
+	if i.thrown != nil {
+		return nil
+	}
+
+	instance, ok := object.(Object)
 
 	if !ok {
-		i.panic(&RuntimeError{g.name, "Only class instances can have properties"})
+		i.throw(&RuntimeError{g.name, "Only objects can have properties"})
+		return nil
+	}
+
+	if klass := members(object); klass != nil {
+		if getter, ok := klass.getters[g.name.lexeme]; ok {
+			return getter.bind(object).call(i)
+		}
 	}
 
 	val, ok := instance.get(g.name.lexeme)
 
 	if !ok {
-		i.panic(&RuntimeError{g.name, fmt.Sprintf("Undefined propery %s", g.name.lexeme)})
+		i.throw(&RuntimeError{g.name, fmt.Sprintf("Undefined propery %s", g.name.lexeme)})
 	}
 
 	return val
@@ -224,52 +531,210 @@ func (i *Interpreter) visitSet(s *Set) any {
 
 	object := i.evaluate(s.obj)
 
-	instance, ok := object.(*ClassInstance)
+	if i.thrown != nil {
+		return nil
+	}
+
+	instance, ok := object.(Fields)
 
 	if !ok {
-		i.panic(&RuntimeError{s.name, "Only class instances have fields."})
+		i.throw(&RuntimeError{s.name, "Only class instances have fields."})
+		return nil
 	}
 
 	value := i.evaluate(s.value)
 
+	if i.thrown != nil {
+		return nil
+	}
+
+	if klass := members(object); klass != nil {
+		if setter, ok := klass.setters[s.name.lexeme]; ok {
+			setter.bind(object).call(i, value)
+			return value
+		}
+	}
+
 	instance.set(s.name.lexeme, value)
 
 	return value
 }
 
-func (i *Interpreter) visitFunStmt(f *FunStmt) {
-	i.env.define(f.name.lexeme, newFunction(f.name, f.args, f.body, i.env))
+func (i *Interpreter) visitFunStmt(f *FunStmt) *Completion {
+	fun := newFunction(f.name, f.args, f.body, f.generator, i.env)
+	fun.returns = f.returns
+	i.declare(f.name, fun, f.constant)
+	return nil
 }
 
-func (i *Interpreter) visitClassStmt(c *ClassStmt) {
-	i.env.define(c.name.lexeme, nil)
-	class := &Class{c.name.lexeme}
-	i.env.assign(c.name, class)
+// visitClassStmt copies methods of traits into the class. Methods
+// from different traits can't share a name unless the class itself
+// overrides it, and overriding has to be explicit.
+func (i *Interpreter) visitClassStmt(c *ClassStmt) *Completion {
+	class := newClass(c.name.lexeme)
+	class.metaclass = newClass(c.name.lexeme + " metaclass")
+
+	own := map[string]Method{}
 
+	for _, method := range c.methods {
+		own[memberKey(method)] = method
+	}
+
+	provided := map[string]*Trait{}
+
+	for _, variable := range c.traits {
+		trait, ok := i.evaluate(variable).(*Trait)
+
+		if i.thrown != nil {
+			return nil
+		}
+
+		if !ok {
+			i.throw(&RuntimeError{variable.name, fmt.Sprintf("%s is not a trait.", variable.name.lexeme)})
+			return nil
+		}
+
+		for _, method := range trait.methods {
+			key := memberKey(method)
+			other, conflict := provided[key]
+
+			if _, overridden := own[key]; conflict && other != trait && !overridden {
+				i.throw(&RuntimeError{c.name, fmt.Sprintf(
+					"Method '%s' comes from both %s and %s, override it in %s.",
+					method.name.lexeme, other.name, trait.name, c.name.lexeme,
+				)})
+				return nil
+			}
+
+			provided[key] = trait
+			class.define(method, trait.closure)
+		}
+	}
+
+	for _, method := range c.methods {
+		trait, fromTrait := provided[memberKey(method)]
+
+		if fromTrait && !method.override {
+			i.throw(&RuntimeError{method.name, fmt.Sprintf(
+				"Method '%s' replaces one from %s, mark it with 'override'.", method.name.lexeme, trait.name,
+			)})
+			return nil
+		}
+
+		if !fromTrait && method.override {
+			i.throw(&RuntimeError{method.name, fmt.Sprintf(
+				"Method '%s' doesn't override any trait method.", method.name.lexeme,
+			)})
+			return nil
+		}
+	}
+
+	for _, method := range c.methods {
+		class.define(method, i.env)
+	}
+
+	i.declare(c.name, class, c.constant)
+	return nil
 }
+
+func (i *Interpreter) visitTraitStmt(t *TraitStmt) *Completion {
+	i.env.define(t.name.lexeme, &Trait{t.name.lexeme, t.methods, i.env})
+	return nil
+}
+
+// isInitializer reports whether method is called when instance is created.
+func isInitializer(method Method) bool {
+	return method.name.lexeme == "init" && method.kind == plainMethod && !method.static
+}
+
+// visitSpread returns list to be expanded by visitCall.
+func (i *Interpreter) visitSpread(s *Spread) any {
+	list, ok := i.evaluate(s.list).(*List)
+
+	if !ok {
+		i.throw(&RuntimeError{s.ellipsis, "Can only spread lists."})
+		return nil
+	}
+
+	return list
+}
+
+func (i *Interpreter) visitThis(t *This) any {
+	return i.lookUpVariable(t.keyword, t)
+}
+
 func (i *Interpreter) visitLambda(l *Lambda) any {
-	return newFunction(l.name, l.args, l.body, i.env)
+	fun := newFunction(l.name, l.args, l.body, l.generator, i.env)
+	fun.returns = l.returns
+	return fun
 }
 
-func (i *Interpreter) visitReturnStmt(r *ReturnStmt) {
+func (i *Interpreter) visitReturnStmt(r *ReturnStmt) *Completion {
+	if i.tco && i.tails[r] {
+		return i.tailCall(r.value.(*Call))
+	}
+
 	var value any
 
 	if r.value != nil {
 		value = i.evaluate(r.value)
 	}
 
-	panic(Return{value})
+	return &Completion{completeReturn, value}
 }
 
-func (i *Interpreter) visitPrintStmt(p *PrintStmt) {
-	fmt.Printf("%v\n", i.evaluate(p.val))
+// tailCall evaluates callee and arguments of call in tail position
+// and returns TailCall instead of calling user functions, so
+// Function.call runs them without growing the stack.
+func (i *Interpreter) tailCall(c *Call) *Completion {
+	callee := i.evaluate(c.callee)
+	args := i.arguments(c.args)
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	fun, ok := i.callable(c.paren, callee, len(args)).(*Function)
+
+	if !ok {
+		return &Completion{completeReturn, i.call(c.paren, callee, args...)}
+	}
+
+	return &Completion{completeReturn, &TailCall{fun, args}}
 }
 
-func (i *Interpreter) visitExprStmt(se *ExprStmt) {
+func (i *Interpreter) visitYieldStmt(y *YieldStmt) *Completion {
+	var value any
+
+	if y.value != nil {
+		value = i.evaluate(y.value)
+	}
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	i.coroutine.yield(value)
+	return nil
+}
+
+func (i *Interpreter) visitPrintStmt(p *PrintStmt) *Completion {
+	str := i.stringify(i.evaluate(p.val))
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	fmt.Fprintln(i.sys.stdout, str)
+	return nil
+}
+
+func (i *Interpreter) visitExprStmt(se *ExprStmt) *Completion {
 	i.evaluate(se.expr)
+	return nil
 }
 
-func (i *Interpreter) visitVarStmt(v *VarStmt) {
+func (i *Interpreter) visitVarStmt(v *VarStmt) *Completion {
 
 	var val any = nil
 
@@ -277,49 +742,90 @@ func (i *Interpreter) visitVarStmt(v *VarStmt) {
 		val = i.evaluate(v.initializer)
 	}
 
-	i.env.define(v.name.lexeme, val)
+	if i.thrown != nil || !i.checkType(v.name, v.typ, val) {
+		return nil
+	}
+
+	i.declare(v.name, val, v.constant)
+	return nil
 }
 
-func (i *Interpreter) visitBlockStmt(b *BlockStmt) {
-	i.executeBlock(b.stmts, newEnvironment(i.env))
+func (i *Interpreter) visitDestructureStmt(d *DestructureStmt) *Completion {
+	bound := i.destructure(d.pattern, i.evaluate(d.initializer))
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	for _, name := range bindings(d.pattern) {
+		i.declare(name, bound[name.lexeme], d.constant)
+	}
+
+	return nil
+}
+
+// declare defines variable in the current environment.
+func (i *Interpreter) declare(name Token, val any, constant bool) {
+	if i.tracer != nil {
+		i.tracer.assign("define", name, val)
+	}
+
+	if err := i.env.declare(name, val, constant); err != nil {
+		i.throw(err)
+	}
+}
+
+func (i *Interpreter) visitBlockStmt(b *BlockStmt) *Completion {
+	return i.executeBlock(b.stmts, newEnvironment(i.env))
 }
 
-func (i *Interpreter) executeBlock(stmts []Stmt, current *Environment) {
+func (i *Interpreter) executeBlock(stmts []Stmt, current *Environment) *Completion {
 
 	parentEnv := i.env
 	i.env = current
 
-	// need to restore environment after
-	// panic(Return) in visitReturnStmt
-	defer func() {
-		i.env = parentEnv
-	}()
-
 	for _, stmt := range stmts {
-
-		if i.brk {
-			break
+		if completion := i.execute(stmt); completion != nil {
+			i.env = parentEnv
+			return completion
 		}
-
-		stmt.accept(i)
 	}
 
+	i.env = parentEnv
+
+	return nil
+}
+
+func (i *Interpreter) visitBreakStmt(b *BreakStmt) *Completion {
+	return breakCompletion
 }
 
-func (i *Interpreter) visitBreakStmt(b *BreakStmt) {
-	i.brk = true
+func (i *Interpreter) visitContinueStmt(c *ContinueStmt) *Completion {
+	return continueCompletion
 }
 
-func (i *Interpreter) visitIfStmt(iff *IfStmt) {
-	if isTruthy(i.evaluate(iff.cond)) {
-		iff.then.accept(i)
+func (i *Interpreter) visitIfStmt(iff *IfStmt) *Completion {
+	cond := i.evaluate(iff.cond)
 
-	} else if iff.or != nil {
-		iff.or.accept(i)
+	if i.thrown != nil {
+		return nil
 	}
+
+	if isTruthy(cond) {
+		i.coverage.take(iff, 0)
+		return i.execute(iff.then)
+	}
+
+	i.coverage.take(iff, 1)
+
+	if iff.or != nil {
+		return i.execute(iff.or)
+	}
+
+	return nil
 }
 
-func (i *Interpreter) visitEnvStmt(e *EnvStmt) {
+func (i *Interpreter) visitEnvStmt(e *EnvStmt) *Completion {
 
 	walker := i.env
 
@@ -330,60 +836,122 @@ func (i *Interpreter) visitEnvStmt(e *EnvStmt) {
 		walker = walker.enclosing
 	}
 
-	fmt.Printf("globals: %+v\n", *i.globals)
+	fmt.Fprintf(i.sys.stdout, "globals: %s\n", i.globals.dump())
 
 	for ident, e := range flatten {
-		fmt.Printf("%*s", ident, "")
-		fmt.Printf("%+v\n", *e)
+		fmt.Fprintf(i.sys.stdout, "%*s", ident, "")
+		fmt.Fprintln(i.sys.stdout, e.dump())
 	}
+
+	return nil
 }
 
-func (i *Interpreter) visitWhileStmt(w *WhileStmt) {
+func (i *Interpreter) visitWhileStmt(w *WhileStmt) *Completion {
 	for isTruthy(i.evaluate(w.cond)) {
 
-		if i.brk {
-			i.brk = false
-			break
+		if completion := i.execute(w.body); completion != nil {
+			if completion.kind == completeBreak {
+				break
+			}
+
+			if completion.kind != completeContinue {
+				return completion
+			}
+		}
+
+		if w.incr != nil {
+			i.evaluate(w.incr)
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitForInStmt(f *ForInStmt) *Completion {
+	iterable := i.evaluate(f.iterable)
+
+	if i.thrown != nil {
+		return nil
+	}
+
+	iterator := i.iterator(f.keyword, iterable)
+
+	if iterator == nil {
+		return nil
+	}
+
+	body := []Stmt{f.body}
+
+	// iterators driving user code or waiting
+	// for channels can throw in done and next
+	for !iterator.done() && i.thrown == nil {
+		value := iterator.next()
+
+		if i.thrown != nil {
+			return nil
 		}
 
-		w.body.accept(i)
+		// closures in the body capture value of this iteration
+		env := newEnvironment(i.env)
+		env.define(f.name.lexeme, value)
+
+		if completion := i.executeBlock(body, env); completion != nil {
+			if completion.kind == completeBreak {
+				break
+			}
+
+			if completion.kind != completeContinue {
+				return completion
+			}
+		}
 	}
+
+	return nil
 }
 
-func (i *Interpreter) resolve(expr Expr, depth int) {
-	i.locals[expr] = depth
+func (i *Interpreter) resolve(expr Expr, slot Slot) {
+	i.locals[expr] = slot
+}
+
+func (i *Interpreter) tailCallAt(r *ReturnStmt) {
+	i.tails[r] = true
 }
 
 func (i *Interpreter) lookUpVariable(name Token, expr Expr) any {
-	distance, isLocal := i.locals[expr]
+	slot, isLocal := i.locals[expr]
 
 	if !isLocal {
 		return i.globals.get(name.lexeme)
 	}
 
-	return i.env.getAt(distance, name.lexeme)
+	return i.env.getAt(slot.depth, slot.index)
 }
 
-func (i *Interpreter) panic(re *RuntimeError) {
-	i.errors = append(i.errors, re)
-	log.Println(re)
-	panic(re)
+// throw raises runtime error, the first one raised
+// by the statement is kept. Callers must not use
+// values they got after the error.
+func (i *Interpreter) throw(re *RuntimeError) {
+	if i.thrown == nil {
+		i.thrown = re
+	}
 }
 
-func (i *Interpreter) checkIfFloat(op Token, val any) {
+func (i *Interpreter) checkIfFloat(op Token, val any) bool {
 	if _, ok := val.(float64); ok {
-		return
+		return true
 	}
 
-	i.panic(&RuntimeError{op, "value must be a number."})
+	i.throw(&RuntimeError{op, "value must be a number."})
+	return false
 }
 
-func (i *Interpreter) checkIfFloats(op Token, a any, b any) {
+func (i *Interpreter) checkIfFloats(op Token, a any, b any) bool {
 	if isFloats(a, b) {
-		return
+		return true
 	}
 
-	i.panic(&RuntimeError{op, fmt.Sprintf("Operands must be numbers: %v %s %v", a, op.lexeme, b)})
+	i.throw(&RuntimeError{op, fmt.Sprintf("Operands must be numbers: %s %s %s", stringify(a), op.lexeme, stringify(b))})
+	return false
 }
 
 func isFloats(a any, b any) bool {
@@ -398,18 +966,6 @@ func isFloats(a any, b any) bool {
 	return ltype.Kind() == rtype.Kind() && ltype.Kind() == reflect.Float64
 }
 
-func isStrings(a any, b any) bool {
-
-	if a == nil || b == nil {
-		return false
-	}
-
-	ltype := reflect.TypeOf(a)
-	rtype := reflect.TypeOf(b)
-
-	return ltype.Kind() == rtype.Kind() && ltype.Kind() == reflect.String
-}
-
 func isTruthy(val any) bool {
 	if val == nil {
 		return false
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func benchmarkScript(b *testing.B, source string) {
+	interpreter := testInterpreter(io.Discard)
+	stmts := compileScript(b, interpreter, source)
+
+	b.ResetTimer()
+
+	for range b.N {
+		if err := interpreter.interpret(stmts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkScript(b, `
+		fun fib(n) {
+			if (n <= 1) return n;
+			return fib(n - 2) + fib(n - 1);
+		}
+		print fib(20);
+	`)
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmarkScript(b, `
+		{
+			var sum = 0;
+			for (var i = 0; i < 10000; i = i + 1) {
+				var square = i * i;
+				{
+					sum = sum + square - i;
+				}
+			}
+			print sum;
+		}
+	`)
+}
+
+func TestTailCallsRunInConstantStack(t *testing.T) {
+	source := `
+		fun count(n) {
+			if (n == 0) return "done";
+			return count(n - 1);
+		}
+		print count(100000);
+	`
+
+	out := &strings.Builder{}
+
+	// without the trampoline this depth needs hundreds of megabytes
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	runScript(t, testInterpreter(out), source)
+
+	if out.String() != "done\n" {
+		t.Fatalf("expected done, got %q", out.String())
+	}
+}
+
+func TestRuntimeErrorRestoresEnvironment(t *testing.T) {
+	interpreter := testInterpreter(io.Discard)
+	stmts := compileScript(t, interpreter, `
+		fun fail(x) {
+			{
+				var local = x;
+				return local + nil;
+			}
+		}
+		{
+			var outer = 1;
+			for (var i in range(3)) fail(i);
+		}
+	`)
+
+	err := interpreter.interpret(stmts)
+
+	if err == nil || !strings.Contains(err.Error(), "Operands must be numbers or strings: 0 + nil") {
+		t.Fatalf("expected runtime error, got %v", err)
+	}
+
+	// error is passed up as completion through every block,
+	// so each of them restores its environment
+	if interpreter.env != interpreter.globals || interpreter.thrown != nil {
+		t.Fatal("expected interpreter to be back in global environment")
+	}
+}
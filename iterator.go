@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Iterator walks over values of iterable in "for in" loop.
+type Iterator interface {
+	done() bool
+	next() any
+}
+
+// iterator returns Iterator for lists, map keys, string characters,
+// ranges, generators and objects implementing iterator protocol: "iterator()"
+// method returning an object with "next()" method and "done" property.
+func (i *Interpreter) iterator(token Token, iterable any) Iterator {
+	switch val := iterable.(type) {
+	case *List:
+		return &listIterator{list: val}
+	case *Map:
+		// keys added in the loop are not visited
+		return &listIterator{list: newList(val.keysList()...)}
+	case string:
+		return &stringIterator{val}
+	case *Range:
+		return &rangeIterator{val, val.from}
+	case *Generator:
+		return &generatorIterator{i, token, val}
+	case *Channel:
+		return &channelIterator{interpreter: i, token: token, channel: val}
+	case Object:
+		if method, ok := val.get("iterator"); ok {
+			return i.protocolIterator(token, i.call(token, method))
+		}
+	}
+
+	i.throw(&RuntimeError{token, fmt.Sprintf("Can't iterate over %s.", stringify(iterable))})
+	return nil
+}
+
+// list can change while iterating,
+// so element is taken in done()
+type listIterator struct {
+	list  *List
+	idx   int
+	value any
+}
+
+func (li *listIterator) done() bool {
+	val, ok := li.list.at(li.idx)
+	li.value = val
+	return !ok
+}
+
+func (li *listIterator) next() any {
+	li.idx++
+	return li.value
+}
+
+type stringIterator struct {
+	rest string
+}
+
+func (si *stringIterator) done() bool {
+	return si.rest == ""
+}
+
+func (si *stringIterator) next() any {
+	_, size := utf8.DecodeRuneInString(si.rest)
+	char := si.rest[:size]
+	si.rest = si.rest[size:]
+	return char
+}
+
+// Range is a sequence of numbers from "from" up to, but not
+// including, "to" with "step" between them.
+type Range struct {
+	from float64
+	to   float64
+	step float64
+}
+
+func (r *Range) String() string {
+	return fmt.Sprintf("range(%s, %s, %s)", formatNumber(r.from), formatNumber(r.to), formatNumber(r.step))
+}
+
+// range(to), range(from, to), range(from, to, step)
+func makeRange(i *Interpreter, args ...any) (any, error) {
+	bounds := []float64{0, 0, 1}
+
+	for idx, arg := range args {
+		num, ok := arg.(float64)
+
+		if !ok {
+			return nil, fmt.Errorf("expected number arguments, got %s", stringify(arg))
+		}
+
+		bounds[idx] = num
+	}
+
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+
+	if bounds[2] == 0 {
+		return nil, errors.New("step can't be zero")
+	}
+
+	return &Range{bounds[0], bounds[1], bounds[2]}, nil
+}
+
+type rangeIterator struct {
+	rng     *Range
+	current float64
+}
+
+func (ri *rangeIterator) done() bool {
+	if ri.rng.step > 0 {
+		return ri.current >= ri.rng.to
+	}
+
+	return ri.current <= ri.rng.to
+}
+
+func (ri *rangeIterator) next() any {
+	val := ri.current
+	ri.current += ri.rng.step
+	return val
+}
+
+// protocolIterator drives user defined iterator object.
+type protocolIterator struct {
+	interpreter *Interpreter
+	token       Token
+	iterator    Object
+}
+
+func (i *Interpreter) protocolIterator(token Token, iterator any) Iterator {
+	if i.thrown != nil {
+		return nil
+	}
+
+	object, ok := iterator.(Object)
+
+	if !ok {
+		i.throw(&RuntimeError{token, fmt.Sprintf("iterator() must return an object, got %s.", stringify(iterator))})
+		return nil
+	}
+
+	return &protocolIterator{i, token, object}
+}
+
+// member returns nil and throws when iterator doesn't have it,
+// calling nil throws too, so callers don't need to check.
+func (pi *protocolIterator) member(name string) any {
+	val, ok := pi.iterator.get(name)
+
+	if !ok {
+		pi.interpreter.throw(&RuntimeError{pi.token, fmt.Sprintf("Iterator has no '%s'.", name)})
+	}
+
+	return val
+}
+
+// done can be a field or a method
+func (pi *protocolIterator) done() bool {
+	done := pi.member("done")
+
+	if _, ok := done.(Callable); ok {
+		done = pi.interpreter.call(pi.token, done)
+	}
+
+	return isTruthy(done)
+}
+
+func (pi *protocolIterator) next() any {
+	return pi.interpreter.call(pi.token, pi.member("next"))
+}
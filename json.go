@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+func jsonNamespace() *Namespace {
+	stringify := newNative("json.stringify", 1, jsonStringify)
+	stringify.max = 2
+
+	return &Namespace{"json", map[string]any{
+		"parse":     newNative("json.parse", 1, jsonParse),
+		"stringify": stringify,
+	}}
+}
+
+// json.parse(str)
+func jsonParse(i *Interpreter, args ...any) (any, error) {
+	str, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := &jsonDecoder{json.NewDecoder(strings.NewReader(str)), str}
+
+	val, err := decoder.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.dec.Token(); err != io.EOF {
+		return nil, decoder.error(errors.New("unexpected data after top-level value"))
+	}
+
+	return val, nil
+}
+
+// jsonDecoder walks the token stream instead of unmarshaling into
+// map[string]any to keep the order of object keys.
+type jsonDecoder struct {
+	dec    *json.Decoder
+	source string
+}
+
+func (jd *jsonDecoder) value() (any, error) {
+	token, err := jd.dec.Token()
+
+	if err != nil {
+		return nil, jd.error(err)
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '[':
+			return jd.list()
+		case '{':
+			return jd.object()
+		}
+		return nil, jd.error(fmt.Errorf("unexpected %s", token))
+	case float64, string, bool, nil:
+		return token, nil
+	}
+
+	return nil, jd.error(fmt.Errorf("unexpected token %v", token))
+}
+
+func (jd *jsonDecoder) list() (any, error) {
+	elements := []any{}
+
+	for jd.dec.More() {
+		el, err := jd.value()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, el)
+	}
+
+	// closing "]"
+	if _, err := jd.dec.Token(); err != nil {
+		return nil, jd.error(err)
+	}
+
+	return newList(elements...), nil
+}
+
+func (jd *jsonDecoder) object() (any, error) {
+	obj := newMap()
+
+	for jd.dec.More() {
+		key, err := jd.dec.Token()
+		if err != nil {
+			return nil, jd.error(err)
+		}
+
+		val, err := jd.value()
+		if err != nil {
+			return nil, err
+		}
+
+		obj.put(key.(string), val)
+	}
+
+	// closing "}"
+	if _, err := jd.dec.Token(); err != nil {
+		return nil, jd.error(err)
+	}
+
+	return obj, nil
+}
+
+// error converts decoder offset to line and column in the source.
+func (jd *jsonDecoder) error(err error) error {
+	offset := jd.dec.InputOffset()
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// syntax error offset points right after the offending byte
+		offset = syntaxErr.Offset - 1
+	}
+
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = errors.New("unexpected end of JSON input")
+		offset = int64(len(jd.source))
+	}
+
+	consumed := jd.source[:min(int(offset), len(jd.source))]
+	line := strings.Count(consumed, "\n") + 1
+	column := len(consumed) - strings.LastIndex(consumed, "\n")
+
+	return fmt.Errorf("%s at line %d, column %d", err, line, column)
+}
+
+// longest indent json.stringify accepts, like in JavaScript
+const maxIndent = 10
+
+// json.stringify(value, indent?)
+func jsonStringify(i *Interpreter, args ...any) (any, error) {
+	indent := ""
+
+	if len(args) > 1 && args[1] != nil {
+		switch val := args[1].(type) {
+		case float64:
+			if val < 0 || val > maxIndent || val != math.Trunc(val) {
+				return nil, fmt.Errorf("indent must be an integer from 0 to %d, got %s", maxIndent, stringify(val))
+			}
+			indent = strings.Repeat(" ", int(val))
+		case string:
+			if utf8.RuneCountInString(val) > maxIndent {
+				return nil, fmt.Errorf("indent must be at most %d characters, got %q", maxIndent, val)
+			}
+			indent = val
+		default:
+			return nil, fmt.Errorf("indent must be a number or a string, got %v", val)
+		}
+	}
+
+	encoder := &jsonEncoder{visiting: map[any]bool{}}
+
+	if err := encoder.encode(args[0]); err != nil {
+		return nil, err
+	}
+
+	if indent == "" {
+		return encoder.buf.String(), nil
+	}
+
+	indented := bytes.Buffer{}
+
+	if err := json.Indent(&indented, encoder.buf.Bytes(), "", indent); err != nil {
+		return nil, err
+	}
+
+	return indented.String(), nil
+}
+
+type jsonEncoder struct {
+	buf      bytes.Buffer
+	visiting map[any]bool
+}
+
+func (je *jsonEncoder) encode(val any) error {
+	switch val := val.(type) {
+	case nil:
+		je.buf.WriteString("null")
+	case bool:
+		je.buf.WriteString(fmt.Sprint(val))
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return fmt.Errorf("can't serialize %v", val)
+		}
+		encoded, _ := json.Marshal(val)
+		je.buf.Write(encoded)
+	case string:
+		je.string(val)
+	case *List:
+		return je.container(val, func() error {
+			je.buf.WriteString("[")
+			for idx, el := range val.items() {
+				if idx > 0 {
+					je.buf.WriteString(",")
+				}
+				if err := je.encode(el); err != nil {
+					return err
+				}
+			}
+			je.buf.WriteString("]")
+			return nil
+		})
+	case *Map:
+		return je.container(val, func() error {
+			return je.object(val.keyNames(), val.lookup)
+		})
+	case *ClassInstance:
+		return je.container(val, func() error {
+			return je.object(val.fields(), val.get)
+		})
+	case *Function:
+		return fmt.Errorf("can't serialize function %s", val.name.lexeme)
+	case *Class:
+		return fmt.Errorf("can't serialize class %s", val.name)
+	default:
+		return fmt.Errorf("can't serialize %v", val)
+	}
+
+	return nil
+}
+
+func (je *jsonEncoder) container(val any, encode func() error) error {
+	if je.visiting[val] {
+		return errors.New("can't serialize cyclic structure")
+	}
+
+	je.visiting[val] = true
+	defer delete(je.visiting, val)
+
+	return encode()
+}
+
+func (je *jsonEncoder) object(keys []string, lookup func(string) (any, bool)) error {
+	je.buf.WriteString("{")
+	for idx, key := range keys {
+		if idx > 0 {
+			je.buf.WriteString(",")
+		}
+		je.string(key)
+		je.buf.WriteString(":")
+		val, _ := lookup(key)
+		if err := je.encode(val); err != nil {
+			return err
+		}
+	}
+	je.buf.WriteString("}")
+	return nil
+}
+
+func (je *jsonEncoder) string(str string) {
+	encoder := json.NewEncoder(&je.buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(str)
+	// Encode always terminates value with a newline
+	je.buf.Truncate(je.buf.Len() - 1)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestJSONStringifyIndent(t *testing.T) {
+	value := newList(1.0)
+
+	valid := map[any]string{
+		nil:  "[1]",
+		0.0:  "[1]",
+		2.0:  "[\n  1\n]",
+		"\t": "[\n\t1\n]",
+	}
+
+	for indent, expected := range valid {
+		got, err := jsonStringify(nil, value, indent)
+
+		if err != nil || got != expected {
+			t.Errorf("indent %v: expected %q, got %q, %v", indent, expected, got, err)
+		}
+	}
+
+	invalid := map[any]string{
+		-1.0:                    "indent must be an integer from 0 to 10, got -1",
+		1.5:                     "indent must be an integer from 0 to 10, got 1.5",
+		11.0:                    "indent must be an integer from 0 to 10, got 11",
+		1e300:                   "indent must be an integer from 0 to 10, got 1e+300",
+		strings.Repeat("-", 11): "indent must be at most 10 characters",
+		true:                    "indent must be a number or a string",
+	}
+
+	for indent, expected := range invalid {
+		_, err := jsonStringify(nil, value, indent)
+
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("indent %v: expected error %q, got %v", indent, expected, err)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	sources := []string{
+		`[1,2.5,-3e-7,true,false,null,"text"]`,
+		`{"b":1,"a":{"z":[],"y":{}}}`,
+		`"<tag> & \"quotes\" \n"`,
+		`[]`,
+	}
+
+	for _, source := range sources {
+		value, err := jsonParse(nil, source)
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+
+		// object keys keep their order
+		encoded, err := jsonStringify(nil, value)
+
+		if err != nil || encoded != source {
+			t.Errorf("expected %s, got %v, %v", source, encoded, err)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	parse := map[string]string{
+		"[1, 2":           "unexpected end of JSON input at line 1",
+		"[1]\n[2]":        "unexpected data after top-level value at line 2",
+		"{\n  \"a\" 1\n}": "at line 2, column 7",
+	}
+
+	for source, expected := range parse {
+		_, err := jsonParse(nil, source)
+
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%q: expected error %q, got %v", source, expected, err)
+		}
+	}
+
+	stringify := map[string]any{
+		"can't serialize NaN":        math.NaN(),
+		"can't serialize +Inf":       math.Inf(1),
+		"can't serialize function f": &Function{name: Token{lexeme: "f"}},
+	}
+
+	for expected, value := range stringify {
+		_, err := jsonStringify(nil, newList(value))
+
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
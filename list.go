@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// List can be shared between spawned tasks,
+// elements are accessed under the lock.
+type List struct {
+	elements []any
+	mu       sync.Mutex
+}
+
+func newList(elements ...any) *List {
+	return &List{elements: elements}
+}
+
+// items returns copy of elements.
+func (l *List) items() []any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return slices.Clone(l.elements)
+}
+
+// at returns element at idx, false when out of range.
+func (l *List) at(idx int) (any, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if idx >= len(l.elements) {
+		return nil, false
+	}
+
+	return l.elements[idx], true
+}
+
+func (l *List) String() string {
+	return stringify(l)
+}
+
+// format converts list to string using
+// element function for each element.
+func (l *List) format(element func(any) string) string {
+	str := strings.Builder{}
+	str.WriteString("[")
+	// element may run user code touching the list
+	for idx, el := range l.items() {
+		if idx > 0 {
+			str.WriteString(", ")
+		}
+		str.WriteString(element(el))
+	}
+	str.WriteString("]")
+	return str.String()
+}
+
+func (l *List) get(name string) (any, bool) {
+	switch name {
+	case "length":
+		return newNative("length", 0, func(i *Interpreter, args ...any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return float64(len(l.elements)), nil
+		}), true
+	case "get":
+		return newNative("get", 1, func(i *Interpreter, args ...any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			idx, err := l.index(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return l.elements[idx], nil
+		}), true
+	case "set":
+		return newNative("set", 2, func(i *Interpreter, args ...any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			idx, err := l.index(args[0])
+			if err != nil {
+				return nil, err
+			}
+			l.elements[idx] = args[1]
+			return args[1], nil
+		}), true
+	case "push":
+		return newNative("push", 1, func(i *Interpreter, args ...any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.elements = append(l.elements, args[0])
+			return nil, nil
+		}), true
+	case "pop":
+		return newNative("pop", 0, func(i *Interpreter, args ...any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if len(l.elements) == 0 {
+				return nil, fmt.Errorf("can't pop from empty list")
+			}
+			last := l.elements[len(l.elements)-1]
+			l.elements = l.elements[:len(l.elements)-1]
+			return last, nil
+		}), true
+	}
+
+	return nil, false
+}
+
+func (l *List) index(val any) (int, error) {
+	num, ok := val.(float64)
+
+	if !ok || num != float64(int(num)) {
+		return 0, fmt.Errorf("list index must be an integer, got %v", val)
+	}
+
+	idx := int(num)
+
+	if idx < 0 || idx >= len(l.elements) {
+		return 0, fmt.Errorf("list index %d out of range [0, %d)", idx, len(l.elements))
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Map keeps string keys in insertion order so printing
+// and serialization are deterministic. Maps can be shared
+// between spawned tasks, so all access goes through the lock.
+type Map struct {
+	keys   []string
+	values map[string]any
+	mu     sync.Mutex
+}
+
+func newMap() *Map {
+	return &Map{keys: []string{}, values: map[string]any{}}
+}
+
+func (m *Map) String() string {
+	return stringify(m)
+}
+
+// format converts map to string using
+// value function for each value.
+func (m *Map) format(value func(any) string) string {
+	str := strings.Builder{}
+	str.WriteString("{")
+	// value may run user code touching the map
+	for idx, key := range m.keyNames() {
+		val, _ := m.lookup(key)
+		if idx > 0 {
+			str.WriteString(", ")
+		}
+		str.WriteString(key)
+		str.WriteString(": ")
+		str.WriteString(value(val))
+	}
+	str.WriteString("}")
+	return str.String()
+}
+
+func (m *Map) lookup(key string) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	val, ok := m.values[key]
+	return val, ok
+}
+
+func (m *Map) put(key string, val any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = val
+}
+
+func (m *Map) remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	m.keys = slices.DeleteFunc(m.keys, func(k string) bool { return k == key })
+}
+
+func (m *Map) get(name string) (any, bool) {
+	switch name {
+	case "length":
+		return newNative("length", 0, func(i *Interpreter, args ...any) (any, error) {
+			return float64(len(m.keyNames())), nil
+		}), true
+	case "get":
+		return newNative("get", 1, func(i *Interpreter, args ...any) (any, error) {
+			key, err := stringArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			val, _ := m.lookup(key)
+			return val, nil
+		}), true
+	case "set":
+		return newNative("set", 2, func(i *Interpreter, args ...any) (any, error) {
+			key, err := stringArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			m.put(key, args[1])
+			return args[1], nil
+		}), true
+	case "has":
+		return newNative("has", 1, func(i *Interpreter, args ...any) (any, error) {
+			key, err := stringArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			_, ok := m.lookup(key)
+			return ok, nil
+		}), true
+	case "remove":
+		return newNative("remove", 1, func(i *Interpreter, args ...any) (any, error) {
+			key, err := stringArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			m.remove(key)
+			return nil, nil
+		}), true
+	case "keys":
+		return newNative("keys", 0, func(i *Interpreter, args ...any) (any, error) {
+			return newList(m.keysList()...), nil
+		}), true
+	}
+
+	return nil, false
+}
+
+// keyNames returns copy of keys in insertion order.
+func (m *Map) keyNames() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.keys)
+}
+
+func (m *Map) keysList() []any {
+	keys := []any{}
+	for _, key := range m.keyNames() {
+		keys = append(keys, key)
+	}
+	return keys
+}
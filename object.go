@@ -0,0 +1,29 @@
+package main
+
+import "fmt"
+
+// Object is anything that exposes properties through ".".
+type Object interface {
+	get(name string) (any, bool)
+}
+
+// Fields is an Object with properties assignable through ".".
+type Fields interface {
+	Object
+	set(name string, val any)
+}
+
+// Namespace groups natives under a common name, like "json.parse".
+type Namespace struct {
+	name    string
+	members map[string]any
+}
+
+func (ns *Namespace) get(name string) (any, bool) {
+	val, ok := ns.members[name]
+	return val, ok
+}
+
+func (ns *Namespace) String() string {
+	return fmt.Sprintf("<namespace %s>", ns.name)
+}
@@ -0,0 +1,385 @@
+package main
+
+// Optimizer rewrites parsed statements into simpler ones with the same
+// behaviour: folds constant expressions, drops branches and loops that
+// can never run, inlines groupings and collapses blocks that don't
+// declare anything into enclosing statements.
+//
+// Program is resolved once before optimizing, so resolve errors
+// inside of dead code are reported, and once more after it.
+type Optimizer struct {
+	// replacement for the last visited statement:
+	// empty when statement is removed, several
+	// statements when block is collapsed
+	stmts []Stmt
+}
+
+func newOptimizer() *Optimizer {
+	return &Optimizer{}
+}
+
+func (o *Optimizer) optimize(stmts []Stmt) []Stmt {
+	optimized := []Stmt{}
+
+	for _, stmt := range stmts {
+		optimized = append(optimized, o.stmt(stmt)...)
+	}
+
+	return optimized
+}
+
+func (o *Optimizer) stmt(stmt Stmt) []Stmt {
+	stmt.accept(o)
+	return o.stmts
+}
+
+// single optimizes statement in a place where
+// exactly one statement is expected, like loop body.
+func (o *Optimizer) single(stmt Stmt) Stmt {
+	stmts := o.stmt(stmt)
+
+	if len(stmts) == 1 {
+		return stmts[0]
+	}
+
+	return &BlockStmt{stmts}
+}
+
+func (o *Optimizer) expr(expr Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+
+	return expr.accept(o).(Expr)
+}
+
+func (o *Optimizer) exprs(exprs []Expr) []Expr {
+	optimized := []Expr{}
+
+	for _, expr := range exprs {
+		optimized = append(optimized, o.expr(expr))
+	}
+
+	return optimized
+}
+
+func (o *Optimizer) visitUnary(u *Unary) any {
+	right := o.expr(u.right)
+
+	if literal, ok := right.(*Literal); ok {
+		switch u.op.typ {
+		case BANG:
+			return &Literal{u.op, !isTruthy(literal.value)}
+		case MINUS:
+			if num, ok := literal.value.(float64); ok {
+				return &Literal{u.op, -num}
+			}
+		}
+	}
+
+	return &Unary{u.op, right}
+}
+
+func (o *Optimizer) visitBinary(b *Binary) any {
+	left := o.expr(b.left)
+	right := o.expr(b.right)
+
+	l, lok := left.(*Literal)
+	r, rok := right.(*Literal)
+
+	if lok && rok {
+		if folded, ok := fold(b.op, l.value, r.value); ok {
+			return &Literal{l.token, folded}
+		}
+	}
+
+	return &Binary{left, b.op, right}
+}
+
+// fold computes binary operation on literal values the same way
+// Interpreter does. Operations that would fail at runtime are
+// not folded, so the error is still reported.
+func fold(op Token, left any, right any) (any, bool) {
+	switch op.typ {
+	case EQUAL_EQUAL:
+		return left == right, true
+	case BANG_EQUAL:
+		return left != right, true
+	}
+
+	_, lstring := left.(string)
+	_, rstring := right.(string)
+
+	// literals are never instances, so converting them
+	// to string doesn't call user code
+	if (lstring || rstring) && op.typ == PLUS {
+		return stringify(left) + stringify(right), true
+	}
+
+	if !isFloats(left, right) {
+		return nil, false
+	}
+
+	l, r := left.(float64), right.(float64)
+
+	switch op.typ {
+	case PLUS:
+		return l + r, true
+	case MINUS:
+		return l - r, true
+	case STAR:
+		return l * r, true
+	case SLASH:
+		return l / r, true
+	case GREATER:
+		return l > r, true
+	case GREATER_EQUAL:
+		return l >= r, true
+	case LESS:
+		return l < r, true
+	case LESS_EQUAL:
+		return l <= r, true
+	}
+
+	return nil, false
+}
+
+func (o *Optimizer) visitLiteral(l *Literal) any {
+	return l
+}
+
+func (o *Optimizer) visitGrouping(g *Grouping) any {
+	return o.expr(g.expression)
+}
+
+func (o *Optimizer) visitVariable(v *Variable) any {
+	return v
+}
+
+func (o *Optimizer) visitAssignment(a *Assign) any {
+	return &Assign{a.variable, o.expr(a.value)}
+}
+
+func (o *Optimizer) visitDestructure(d *Destructure) any {
+	return &Destructure{d.equal, d.pattern, d.targets, o.expr(d.value)}
+}
+
+func (o *Optimizer) visitList(l *ListExpr) any {
+	return &ListExpr{l.bracket, o.exprs(l.elements)}
+}
+
+func (o *Optimizer) visitLogical(l *Logical) any {
+	left := o.expr(l.left)
+	right := o.expr(l.right)
+
+	literal, ok := left.(*Literal)
+
+	if !ok {
+		return &Logical{left, l.operator, right}
+	}
+
+	shortOr := l.operator.typ == OR && isTruthy(literal.value)
+	shortAnd := l.operator.typ == AND && !isTruthy(literal.value)
+
+	if shortOr || shortAnd {
+		return left
+	}
+
+	return right
+}
+
+func (o *Optimizer) visitCall(c *Call) any {
+	return &Call{o.expr(c.callee), c.paren, o.exprs(c.args)}
+}
+
+func (o *Optimizer) visitSpawn(s *Spawn) any {
+	return &Spawn{s.keyword, o.expr(s.call).(*Call)}
+}
+
+func (o *Optimizer) visitMatch(m *Match) any {
+	cases := []Case{}
+
+	for _, c := range m.cases {
+		cases = append(cases, Case{c.patterns, o.expr(c.guard), o.expr(c.body), c.bindings})
+	}
+
+	return &Match{m.keyword, o.expr(m.value), cases}
+}
+
+func (o *Optimizer) visitLambda(l *Lambda) any {
+	return &Lambda{l.name, o.params(l.args), o.optimize(l.body), l.generator, l.returns}
+}
+
+func (o *Optimizer) params(params []Param) []Param {
+	optimized := []Param{}
+
+	for _, param := range params {
+		optimized = append(optimized, Param{param.name, o.expr(param.value), param.rest, param.typ, param.pattern})
+	}
+
+	return optimized
+}
+
+func (o *Optimizer) visitSpread(s *Spread) any {
+	return &Spread{s.ellipsis, o.expr(s.list)}
+}
+
+func (o *Optimizer) visitGet(g *Get) any {
+	return &Get{g.name, o.expr(g.obj)}
+}
+
+func (o *Optimizer) visitSet(s *Set) any {
+	return &Set{s.name, o.expr(s.obj), o.expr(s.value)}
+}
+
+func (o *Optimizer) visitThis(t *This) any {
+	return t
+}
+
+func (o *Optimizer) visitPrintStmt(p *PrintStmt) *Completion {
+	o.stmts = []Stmt{&PrintStmt{p.keyword, o.expr(p.val)}}
+	return nil
+}
+
+func (o *Optimizer) visitExprStmt(es *ExprStmt) *Completion {
+	o.stmts = []Stmt{&ExprStmt{o.expr(es.expr)}}
+	return nil
+}
+
+func (o *Optimizer) visitVarStmt(v *VarStmt) *Completion {
+	o.stmts = []Stmt{&VarStmt{v.name, o.expr(v.initializer), v.typ, v.constant}}
+	return nil
+}
+
+func (o *Optimizer) visitDestructureStmt(d *DestructureStmt) *Completion {
+	o.stmts = []Stmt{&DestructureStmt{d.pattern, o.expr(d.initializer), d.constant}}
+	return nil
+}
+
+func (o *Optimizer) visitBlockStmt(b *BlockStmt) *Completion {
+	stmts := o.optimize(b.stmts)
+
+	if declares(stmts) {
+		o.stmts = []Stmt{&BlockStmt{stmts}}
+		return nil
+	}
+
+	o.stmts = stmts
+	return nil
+}
+
+// declares reports whether statements need their own environment.
+// "env" prints the environment chain, so it needs one as well.
+func declares(stmts []Stmt) bool {
+	for _, stmt := range stmts {
+		switch stmt.(type) {
+		case *VarStmt, *DestructureStmt, *FunStmt, *ClassStmt, *TraitStmt, *EnvStmt:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *Optimizer) visitIfStmt(i *IfStmt) *Completion {
+	cond := o.expr(i.cond)
+
+	literal, ok := cond.(*Literal)
+
+	if ok && isTruthy(literal.value) {
+		o.stmts = o.stmt(i.then)
+		return nil
+	}
+
+	if ok && i.or != nil {
+		o.stmts = o.stmt(i.or)
+		return nil
+	}
+
+	if ok {
+		o.stmts = []Stmt{}
+		return nil
+	}
+
+	then := o.single(i.then)
+
+	var or Stmt
+
+	if i.or != nil {
+		or = o.single(i.or)
+	}
+
+	o.stmts = []Stmt{&IfStmt{i.name, cond, then, or}}
+	return nil
+}
+
+func (o *Optimizer) visitEnvStmt(e *EnvStmt) *Completion {
+	o.stmts = []Stmt{e}
+	return nil
+}
+
+func (o *Optimizer) visitWhileStmt(w *WhileStmt) *Completion {
+	cond := o.expr(w.cond)
+
+	if literal, ok := cond.(*Literal); ok && !isTruthy(literal.value) {
+		o.stmts = []Stmt{}
+		return nil
+	}
+
+	o.stmts = []Stmt{&WhileStmt{cond, o.single(w.body), o.expr(w.incr)}}
+	return nil
+}
+
+func (o *Optimizer) visitForInStmt(f *ForInStmt) *Completion {
+	o.stmts = []Stmt{&ForInStmt{f.keyword, f.name, o.expr(f.iterable), o.single(f.body)}}
+	return nil
+}
+
+func (o *Optimizer) visitBreakStmt(b *BreakStmt) *Completion {
+	o.stmts = []Stmt{b}
+	return nil
+}
+
+func (o *Optimizer) visitContinueStmt(c *ContinueStmt) *Completion {
+	o.stmts = []Stmt{c}
+	return nil
+}
+
+func (o *Optimizer) visitFunStmt(f *FunStmt) *Completion {
+	o.stmts = []Stmt{o.function(f)}
+	return nil
+}
+
+func (o *Optimizer) function(f *FunStmt) *FunStmt {
+	return &FunStmt{f.name, o.params(f.args), o.optimize(f.body), f.generator, f.returns, f.constant}
+}
+
+func (o *Optimizer) visitReturnStmt(r *ReturnStmt) *Completion {
+	o.stmts = []Stmt{&ReturnStmt{r.keyword, o.expr(r.value)}}
+	return nil
+}
+
+func (o *Optimizer) visitYieldStmt(y *YieldStmt) *Completion {
+	o.stmts = []Stmt{&YieldStmt{y.keyword, o.expr(y.value)}}
+	return nil
+}
+
+func (o *Optimizer) visitClassStmt(c *ClassStmt) *Completion {
+	o.stmts = []Stmt{&ClassStmt{c.name, c.traits, o.methods(c.methods), c.constant}}
+	return nil
+}
+
+func (o *Optimizer) visitTraitStmt(t *TraitStmt) *Completion {
+	o.stmts = []Stmt{&TraitStmt{t.name, o.methods(t.methods)}}
+	return nil
+}
+
+func (o *Optimizer) methods(methods []Method) []Method {
+	optimized := []Method{}
+
+	for _, method := range methods {
+		optimized = append(optimized, Method{*o.function(&method.FunStmt), method.kind, method.static, method.override})
+	}
+
+	return optimized
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestOptimizer(t *testing.T) {
+	source := `
+		var a = (1 + 2) * "x";
+		if (false) print "dead";
+		for (a = 1 + 1; a < 10 - 5; a = a + 1) { print "a" + "b"; }
+	`
+	tokens, _ := newScanner([]byte(source)).scan()
+	stmts, _ := newParser(tokens).parse()
+
+	optimized := AstStringer{stmts: newOptimizer().optimize(stmts)}.String()
+
+	expected := "(var a (* 3 x))\n(= a 2)\n(while (< a 5) (print ab) (= a (+ a 1)))"
+
+	if optimized != expected {
+		t.Fatalf("expected %q, got %q", expected, optimized)
+	}
+}
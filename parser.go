@@ -9,6 +9,8 @@ type Parser struct {
 	tokens  []Token
 	current int
 	errors  []error
+	// current function body has "yield"
+	yields bool
 }
 
 type ParseError struct {
@@ -64,12 +66,27 @@ func (p *Parser) declaration() Stmt {
 		return p.classDecl()
 	}
 
+	if p.match(TRAIT) {
+		return p.traitDecl()
+	}
+
+	if p.match(CONST) {
+		return p.constDecl()
+	}
+
 	return p.statement()
 }
 
-// varDecl -> "var" IDENTIFIER ("=" expression)? ";"
+// varDecl -> "var" IDENTIFIER annotation? ("=" expression)? ";"
+//
+//	| "var" destructuring
 func (p *Parser) varDecl() Stmt {
+	if p.check(LEFT_BRACKET) || p.check(LEFT_BRACE) {
+		return p.destructuring(false)
+	}
+
 	name := p.consume(IDENTIFIER, "Expect identifier for variable")
+	typ := p.annotation()
 
 	var initializer Expr = nil
 	if p.match(EQUAL) {
@@ -78,51 +95,223 @@ func (p *Parser) varDecl() Stmt {
 
 	p.consume(SEMICOLON, "Expect ';' after expression in var declaration;")
 
-	return &VarStmt{name, initializer}
+	return &VarStmt{name, initializer, typ, false}
+}
+
+// constDecl -> "const" ( "fun" function | "class" classDecl | IDENTIFIER annotation? "=" expression ";" | destructuring )
+func (p *Parser) constDecl() Stmt {
+	if p.match(FUN) {
+		fun := p.function("function")
+		fun.constant = true
+		return fun
+	}
+
+	if p.match(CLASS) {
+		class := p.classDecl().(*ClassStmt)
+		class.constant = true
+		return class
+	}
+
+	if p.check(LEFT_BRACKET) || p.check(LEFT_BRACE) {
+		return p.destructuring(true)
+	}
+
+	name := p.consume(IDENTIFIER, "Expect constant name.")
+	typ := p.annotation()
+
+	p.consume(EQUAL, "Expect '=' after constant name, constants must be initialized.")
+	initializer := p.expression()
+	p.consume(SEMICOLON, "Expect ';' after constant declaration.")
+
+	return &VarStmt{name, initializer, typ, true}
+}
+
+// destructuring -> ( listPattern | mapPattern ) "=" expression ";"
+func (p *Parser) destructuring(constant bool) Stmt {
+	pattern := p.pattern()
+	p.bindings(pattern)
+
+	p.consume(EQUAL, "Expect '=' after destructuring pattern.")
+	initializer := p.expression()
+	p.consume(SEMICOLON, "Expect ';' after destructuring declaration.")
+
+	return &DestructureStmt{pattern, initializer, constant}
+}
+
+// annotation -> ( ":" ( IDENTIFIER | "nil" | "fun" ) )?
+func (p *Parser) annotation() *Annotation {
+	if !p.match(COLON) {
+		return nil
+	}
+
+	if p.match(IDENTIFIER, NIL, FUN) {
+		return &Annotation{p.previous()}
+	}
+
+	p.panic(&ParseError{p.peek(), "Expect type after ':'."})
+
+	return nil
 }
 
-// classDecl -> "class" IDENTIFIER "{" function* "}"
+// classDecl -> "class" IDENTIFIER ( "with" IDENTIFIER ( "," IDENTIFIER )* )? "{" method* "}"
 func (p *Parser) classDecl() Stmt {
 	name := p.consume(IDENTIFIER, "Expect identifier for variable")
+
+	traits := []*Variable{}
+	if p.match(WITH) {
+		for ok := true; ok; ok = p.match(COMMA) {
+			traits = append(traits, &Variable{p.consume(IDENTIFIER, "Expect trait name.")})
+		}
+	}
+
 	p.consume(LEFT_BRACE, "Expect '{' after class identifier")
 
-	methods := []FunStmt{}
+	methods := p.methods("class")
+	return &ClassStmt{name, traits, methods, false}
+}
+
+// traitDecl -> "trait" IDENTIFIER "{" method* "}"
+func (p *Parser) traitDecl() Stmt {
+	name := p.consume(IDENTIFIER, "Expect trait name.")
+	p.consume(LEFT_BRACE, "Expect '{' after trait name.")
+
+	methods := p.methods("trait")
+
+	for _, method := range methods {
+		if method.override {
+			p.panic(&ParseError{method.name, "Only class methods can override trait methods."})
+		}
+	}
+
+	return &TraitStmt{name, methods}
+}
+
+func (p *Parser) methods(kind string) []Method {
+	methods := []Method{}
 	for !p.isAtEnd() && !p.check(RIGHT_BRACE) {
-		methods = append(methods, *p.function("method"))
+		methods = append(methods, p.method())
 	}
-	p.consume(RIGHT_BRACE, "Expect '}' after class definition")
-	return &ClassStmt{name, methods}
+	p.consume(RIGHT_BRACE, fmt.Sprintf("Expect '}' after %s definition", kind))
+	return methods
+}
+
+// method -> "override"? "class"? ( "set" function | IDENTIFIER annotation? blockStmt | function )
+func (p *Parser) method() Method {
+	// "override" is a keyword only in front of method declaration
+	override := p.check(IDENTIFIER) && p.peek().lexeme == "override" &&
+		(p.peekAt(1).typ == IDENTIFIER || p.peekAt(1).typ == CLASS)
+
+	if override {
+		p.advance()
+	}
+
+	method := p.member()
+	method.override = override
+	return method
+}
+
+func (p *Parser) member() Method {
+	static := p.match(CLASS)
+
+	// "set" is a setter only when followed by its name,
+	// so methods can still be called "set"
+	if p.check(IDENTIFIER) && p.peek().lexeme == "set" && p.peekAt(1).typ == IDENTIFIER {
+		p.advance()
+		setter := p.function("setter")
+
+		if len(setter.args) != 1 || setter.args[0].rest || setter.args[0].value != nil {
+			p.panic(&ParseError{setter.name, "Setter must have exactly one parameter."})
+		}
+
+		return Method{*setter, setterMethod, static, false}
+	}
+
+	if p.check(IDENTIFIER) && (p.peekAt(1).typ == LEFT_BRACE || p.peekAt(1).typ == COLON) {
+		name := p.advance()
+		returns := p.annotation()
+		p.consume(LEFT_BRACE, "Expect '{' after getter name.")
+		body, generator := p.functionBody()
+		return Method{FunStmt{name, []Param{}, body, generator, returns, false}, getterMethod, static, false}
+	}
+
+	return Method{*p.function("method"), plainMethod, static, false}
 }
 
 // funDecl -> "fun" function
-// function -> IDENTIFIER "("  parameters? ")" blockStmt
-// parameters -> IDENTIFIER ( "," IDENTIFIER )*
+// function -> IDENTIFIER "("  parameters? ")" annotation? blockStmt
 func (p *Parser) function(kind string) *FunStmt {
 	name := p.consume(IDENTIFIER, fmt.Sprintf("Expect %s name.", kind))
 
 	p.consume(LEFT_PAREN, fmt.Sprintf("Expect '(' after %s name.", kind))
 
-	args := []Token{}
-	for !p.check(RIGHT_PAREN) {
-		args = append(
-			args,
-			p.consume(
-				IDENTIFIER,
-				fmt.Sprintf("Expect %s argument.", kind),
-			),
-		)
-
-		if p.check(COMMA) {
-			p.advance()
-		}
-	}
+	args := p.parameters(kind)
 
 	p.consume(RIGHT_PAREN, fmt.Sprintf("Expect ')' after %s name.", kind))
+	returns := p.annotation()
 	p.consume(LEFT_BRACE, fmt.Sprintf("Expect '{' after %s arguments.", kind))
 
+	body, generator := p.functionBody()
+
+	return &FunStmt{name, args, body, generator, returns, false}
+}
+
+// functionBody parses function block and reports whether
+// it contains "yield", which makes function a generator.
+func (p *Parser) functionBody() ([]Stmt, bool) {
+	enclosing := p.yields
+	p.yields = false
+	defer func() { p.yields = enclosing }()
+
 	body := p.block()
 
-	return &FunStmt{name, args, body}
+	return body, p.yields
+}
+
+// parameters -> param ( "," param )* ( "," "..." IDENTIFIER annotation? )?
+// param -> ( IDENTIFIER | listPattern | mapPattern ) annotation? ( "=" expression )?
+func (p *Parser) parameters(kind string) []Param {
+	params := []Param{}
+
+	for !p.check(RIGHT_PAREN) && !p.isAtEnd() {
+		if p.match(DOT_DOT_DOT) {
+			name := p.consume(IDENTIFIER, fmt.Sprintf("Expect %s rest argument.", kind))
+			params = append(params, Param{name, nil, true, p.annotation(), nil})
+
+			if !p.check(RIGHT_PAREN) {
+				p.panic(&ParseError{p.peek(), "Rest argument must be the last one."})
+			}
+			break
+		}
+
+		var pattern Pattern
+		var name Token
+
+		if p.check(LEFT_BRACKET) || p.check(LEFT_BRACE) {
+			pattern = p.pattern()
+			p.bindings(pattern)
+			name = patternToken(pattern)
+		} else {
+			name = p.consume(IDENTIFIER, fmt.Sprintf("Expect %s argument.", kind))
+		}
+
+		typ := p.annotation()
+
+		var value Expr
+
+		if p.match(EQUAL) {
+			value = p.expression()
+		} else if len(params) > 0 && params[len(params)-1].value != nil {
+			p.panic(&ParseError{name, "Argument without default value can't follow one with default."})
+		}
+
+		params = append(params, Param{name, value, false, typ, pattern})
+
+		if p.check(COMMA) {
+			p.advance()
+		}
+	}
+
+	return params
 }
 
 // statement ->  exprStmt
@@ -132,8 +321,10 @@ func (p *Parser) function(kind string) *FunStmt {
 //	| printStmt
 //	| blockStmt
 //	| breakStmt
+//	| continueStmt
 //	| ifStmt
 //	| returnStmt
+//	| yieldStmt
 //	| env
 func (p *Parser) statement() Stmt {
 	if p.match(PRINT) {
@@ -164,16 +355,31 @@ func (p *Parser) statement() Stmt {
 		return p.breakStmt()
 	}
 
+	if p.match(CONTINUE) {
+		return p.continueStmt()
+	}
+
 	if p.match(RETURN) {
 		return p.returnStmt()
 	}
 
+	if p.match(YIELD) {
+		return p.yieldStmt()
+	}
+
 	return p.exprStmt()
 }
 
 // exprStmt -> expression ";"
 func (p *Parser) exprStmt() Stmt {
 	expr := p.expression()
+
+	// match used as a statement reads better without ';'
+	if _, ok := expr.(*Match); ok && p.previous().typ == RIGHT_BRACE {
+		p.match(SEMICOLON)
+		return &ExprStmt{expr}
+	}
+
 	p.consume(SEMICOLON, "Expect ';' after statement.")
 
 	if expr == nil {
@@ -185,6 +391,7 @@ func (p *Parser) exprStmt() Stmt {
 
 // printStmt -> "print" expression ";"
 func (p *Parser) printStmt() Stmt {
+	keyword := p.previous()
 	expr := p.expression()
 
 	if expr == nil {
@@ -192,7 +399,7 @@ func (p *Parser) printStmt() Stmt {
 	}
 
 	p.consume(SEMICOLON, "Expect ';' after print expression.")
-	return &PrintStmt{expr}
+	return &PrintStmt{keyword, expr}
 }
 
 func (p *Parser) block() []Stmt {
@@ -214,8 +421,16 @@ func (p *Parser) blockStmt() *BlockStmt {
 
 // breakStmt -> break ";"
 func (p *Parser) breakStmt() Stmt {
+	keyword := p.previous()
 	p.consume(SEMICOLON, "Expect ';' after break.")
-	return &BreakStmt{}
+	return &BreakStmt{keyword}
+}
+
+// continueStmt -> continue ";"
+func (p *Parser) continueStmt() Stmt {
+	keyword := p.previous()
+	p.consume(SEMICOLON, "Expect ';' after continue.")
+	return &ContinueStmt{keyword}
 }
 
 // if -> "if" "(" expression ")" statement ("else" statement)?
@@ -241,18 +456,25 @@ func (p *Parser) whileStmt() Stmt {
 	p.consume(RIGHT_PAREN, "Expect ')' after 'while' expression.")
 	body := p.statement()
 
-	return &WhileStmt{cond, body}
+	return &WhileStmt{cond, body, nil}
 }
 
 // for -> "for" ( "(" ( varDecl | exprStmt | ";" ) expression? ";" expression  ")" )? statement
+//
+//	| forIn
 func (p *Parser) forStmt() Stmt {
+	keyword := p.previous()
 
 	if p.check(LEFT_BRACE) {
-		return &WhileStmt{&Literal{true}, p.statement()}
+		return &WhileStmt{&Literal{keyword, true}, p.statement(), nil}
 	}
 
 	p.consume(LEFT_PAREN, "Expect '(' after 'for'.")
 
+	if p.check(VAR) && p.peekAt(2).typ == IN {
+		return p.forIn(keyword)
+	}
+
 	var init Stmt
 
 	if p.match(SEMICOLON) {
@@ -281,15 +503,11 @@ func (p *Parser) forStmt() Stmt {
 
 	var body = p.statement()
 
-	if incr != nil {
-		body = &BlockStmt{[]Stmt{body, &ExprStmt{incr}}}
-	}
-
 	if cond == nil {
-		cond = &Literal{true}
+		cond = &Literal{keyword, true}
 	}
 
-	body = &WhileStmt{cond, body}
+	body = &WhileStmt{cond, body, incr}
 
 	if init != nil {
 		body = &BlockStmt{[]Stmt{init, body}}
@@ -298,17 +516,50 @@ func (p *Parser) forStmt() Stmt {
 	return body
 }
 
+// forIn -> "for" "(" "var" IDENTIFIER "in" expression ")" statement
+func (p *Parser) forIn(keyword Token) Stmt {
+	p.consume(VAR, "Expect 'var' in for loop.")
+	name := p.consume(IDENTIFIER, "Expect loop variable name.")
+	p.consume(IN, "Expect 'in' after loop variable.")
+	iterable := p.expression()
+	p.consume(RIGHT_PAREN, "Expect ')' after for loop iterable.")
+
+	return &ForInStmt{keyword, name, iterable, p.statement()}
+}
+
 // env -> "env" ";"
 func (p *Parser) envStmt() Stmt {
 	p.consume(SEMICOLON, "Expect ';' after 'env'.")
 	return &EnvStmt{}
 }
 
-// return -> "return" expression ";"
+// return -> "return" expression? ";"
 func (p *Parser) returnStmt() Stmt {
-	ret := p.expression()
+	keyword := p.previous()
+
+	var ret Expr
+
+	if !p.check(SEMICOLON) {
+		ret = p.expression()
+	}
+
 	p.consume(SEMICOLON, "Expect ';' after return;")
-	return &ReturnStmt{ret}
+	return &ReturnStmt{keyword, ret}
+}
+
+// yield -> "yield" expression? ";"
+func (p *Parser) yieldStmt() Stmt {
+	keyword := p.previous()
+	p.yields = true
+
+	var value Expr
+
+	if !p.check(SEMICOLON) {
+		value = p.expression()
+	}
+
+	p.consume(SEMICOLON, "Expect ';' after yield.")
+	return &YieldStmt{keyword, value}
 }
 
 // expression -> assignment
@@ -316,7 +567,7 @@ func (p *Parser) expression() Expr {
 	return p.assignment()
 }
 
-// assignment -> (call ".")? IDENTIFIER "=" assignment | or
+// assignment -> (call ".")? IDENTIFIER "=" assignment | list "=" assignment | or
 func (p *Parser) assignment() Expr {
 	expr := p.or()
 
@@ -328,6 +579,13 @@ func (p *Parser) assignment() Expr {
 			return &Assign{variable.name, val}
 		} else if get, ok := expr.(*Get); ok {
 			return &Set{get.name, get.obj, val}
+		} else if list, ok := expr.(*ListExpr); ok {
+			pattern := p.target(eq, list)
+			targets := []*Variable{}
+			for _, name := range p.bindings(pattern) {
+				targets = append(targets, &Variable{name})
+			}
+			return &Destructure{eq, pattern, targets, val}
 		}
 
 		p.panic(&ParseError{eq, "Invalid assignment target."})
@@ -335,6 +593,34 @@ func (p *Parser) assignment() Expr {
 	return expr
 }
 
+// target converts list of variables on the left side
+// of destructuring assignment to a pattern.
+func (p *Parser) target(eq Token, list *ListExpr) *ListPattern {
+	elements := []Pattern{}
+	var rest *BindPattern
+
+	for idx, element := range list.elements {
+		switch el := element.(type) {
+		case *Variable:
+			elements = append(elements, &BindPattern{el.name})
+			continue
+		case *ListExpr:
+			elements = append(elements, p.target(eq, el))
+			continue
+		case *Spread:
+			variable, ok := el.list.(*Variable)
+			if ok && idx == len(list.elements)-1 {
+				rest = &BindPattern{variable.name}
+				continue
+			}
+		}
+
+		p.panic(&ParseError{eq, "Invalid assignment target."})
+	}
+
+	return &ListPattern{list.bracket, elements, rest}
+}
+
 // or -> and ( "or" and )*
 func (p *Parser) or() Expr {
 	left := p.and()
@@ -414,7 +700,7 @@ func (p *Parser) factor() Expr {
 	return exp
 }
 
-// unary -> ( "!" | "-"  ) unary | primary
+// unary -> ( "!" | "-"  ) unary | spawn | call
 func (p *Parser) unary() Expr {
 	if p.match(BANG, MINUS) {
 		op := p.previous()
@@ -422,9 +708,26 @@ func (p *Parser) unary() Expr {
 		return &Unary{op, right}
 	}
 
+	if p.match(SPAWN) {
+		return p.spawn()
+	}
+
 	return p.call()
 }
 
+// spawn -> "spawn" call
+func (p *Parser) spawn() Expr {
+	keyword := p.previous()
+
+	call, ok := p.call().(*Call)
+
+	if !ok {
+		p.panic(&ParseError{keyword, "Expect function call after 'spawn'."})
+	}
+
+	return &Spawn{keyword, call}
+}
+
 // call ->  primary ( "(" arguments? ")" | "." IDENTIFIER )*
 func (p *Parser) call() Expr {
 	expr := p.primary()
@@ -443,13 +746,19 @@ func (p *Parser) call() Expr {
 	return expr
 }
 
-// arguments ->  expression ( "," expression )*
+// arguments ->  argument ( "," argument )*
+// argument -> "..."? expression
 func (p *Parser) arguments(callee Expr) Expr {
 	arguments := []Expr{}
 
 	if !p.check(RIGHT_PAREN) {
 		for {
-			arguments = append(arguments, p.expression())
+			if p.match(DOT_DOT_DOT) {
+				ellipsis := p.previous()
+				arguments = append(arguments, &Spread{ellipsis, p.expression()})
+			} else {
+				arguments = append(arguments, p.expression())
+			}
 
 			if !p.match(COMMA) {
 				break
@@ -464,29 +773,45 @@ func (p *Parser) arguments(callee Expr) Expr {
 
 // primary -> IDENTIFIER
 //
+//	| "[" arguments? "]"
+//
 //	| NUMBER
 //	| STRING
 //	| "true"
 //	| "false"
 //	| "nil"
+//	| "this"
 //	| "(" expression ")"
 //	| lambda
+//	| match
 func (p *Parser) primary() Expr {
 	switch {
 	case p.match(FALSE):
-		return &Literal{false}
+		return &Literal{p.previous(), false}
 	case p.match(TRUE):
-		return &Literal{true}
+		return &Literal{p.previous(), true}
 	case p.match(NIL):
-		return &Literal{nil}
+		return &Literal{p.previous(), nil}
 	}
 
 	if p.match(FUN) {
 		return p.lambda()
 	}
 
+	if p.match(MATCH) {
+		return p.matchExpr()
+	}
+
+	if p.match(LEFT_BRACKET) {
+		return p.list()
+	}
+
 	if p.match(NUMBER, STRING) {
-		return &Literal{p.previous().literal}
+		return &Literal{p.previous(), p.previous().literal}
+	}
+
+	if p.match(THIS) {
+		return &This{p.previous()}
 	}
 
 	if p.match(IDENTIFIER) {
@@ -504,32 +829,228 @@ func (p *Parser) primary() Expr {
 	return nil
 }
 
-func (p *Parser) lambda() Expr {
-	name := p.previous()
+// list -> "[" ( argument ( "," argument )* )? "]"
+func (p *Parser) list() Expr {
+	bracket := p.previous()
+	elements := []Expr{}
 
-	p.consume(LEFT_PAREN, "Expect '(' before lambda arguments.")
+	for !p.check(RIGHT_BRACKET) && !p.isAtEnd() {
+		if p.match(DOT_DOT_DOT) {
+			ellipsis := p.previous()
+			elements = append(elements, &Spread{ellipsis, p.expression()})
+		} else {
+			elements = append(elements, p.expression())
+		}
+
+		if !p.match(COMMA) {
+			break
+		}
+	}
 
-	args := []Token{}
-	for !p.check(RIGHT_PAREN) {
-		args = append(
-			args,
-			p.consume(
-				IDENTIFIER,
-				"Expect lambda argument.",
-			),
-		)
+	p.consume(RIGHT_BRACKET, "Expect ']' after list elements.")
 
-		if p.check(COMMA) {
-			p.advance()
+	return &ListExpr{bracket, elements}
+}
+
+// match -> "match" "(" expression ")" "{" case* "}"
+func (p *Parser) matchExpr() Expr {
+	keyword := p.previous()
+
+	p.consume(LEFT_PAREN, "Expect '(' after 'match'.")
+	value := p.expression()
+	p.consume(RIGHT_PAREN, "Expect ')' after match value.")
+	p.consume(LEFT_BRACE, "Expect '{' before match cases.")
+
+	cases := []Case{}
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		cases = append(cases, p.matchCase())
+	}
+
+	p.consume(RIGHT_BRACE, "Expect '}' after match cases.")
+
+	return &Match{keyword, value, cases}
+}
+
+// case -> "case" pattern ( "," pattern )* ( "if" expression )? "=>" expression ";"?
+func (p *Parser) matchCase() Case {
+	p.consume(CASE, "Expect 'case' in match.")
+
+	patterns := []Pattern{}
+	names := []Token{}
+	seen := map[string]bool{}
+
+	for ok := true; ok; ok = p.match(COMMA) {
+		pattern := p.pattern()
+		patterns = append(patterns, pattern)
+
+		// every alternative binds to the same case scope
+		for _, name := range p.bindings(pattern) {
+			if !seen[name.lexeme] {
+				seen[name.lexeme] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	var guard Expr
+	if p.match(IF) {
+		guard = p.expression()
+	}
+
+	p.consume(ARROW, "Expect '=>' after case pattern.")
+	body := p.expression()
+	p.match(SEMICOLON)
+
+	return Case{patterns, guard, body, names}
+}
+
+// bindings returns variables bound by pattern,
+// each name can be bound only once.
+func (p *Parser) bindings(pattern Pattern) []Token {
+	names := bindings(pattern)
+	bound := map[string]bool{}
+
+	for _, name := range names {
+		if bound[name.lexeme] {
+			p.panic(&ParseError{name, fmt.Sprintf("Duplicate binding '%s' in pattern.", name.lexeme)})
+		}
+		bound[name.lexeme] = true
+	}
+
+	return names
+}
+
+// pattern -> "_" | IDENTIFIER | IDENTIFIER "(" patterns? ")"
+//
+//	| NUMBER | "-" NUMBER | STRING | "true" | "false" | "nil"
+//	| "[" patterns? ( ","? "..." IDENTIFIER )? "]"
+//	| "{" ( key ( ":" pattern )? ( "," key ( ":" pattern )? )* )? "}"
+//
+// patterns -> pattern ( "," pattern )*
+func (p *Parser) pattern() Pattern {
+	switch {
+	case p.match(FALSE):
+		return &LiteralPattern{p.previous(), false}
+	case p.match(TRUE):
+		return &LiteralPattern{p.previous(), true}
+	case p.match(NIL):
+		return &LiteralPattern{p.previous(), nil}
+	case p.match(NUMBER, STRING):
+		return &LiteralPattern{p.previous(), p.previous().literal}
+	case p.match(MINUS):
+		number := p.consume(NUMBER, "Expect number after '-' in pattern.")
+		return &LiteralPattern{number, -number.literal.(float64)}
+	case p.match(LEFT_BRACKET):
+		return p.listPattern()
+	case p.match(LEFT_BRACE):
+		return p.mapPattern()
+	case p.match(IDENTIFIER):
+		name := p.previous()
+
+		if !p.match(LEFT_PAREN) {
+			return &BindPattern{name}
+		}
+
+		paren := p.previous()
+		args := []Pattern{}
+
+		for !p.check(RIGHT_PAREN) && !p.isAtEnd() {
+			args = append(args, p.pattern())
+
+			if !p.match(COMMA) {
+				break
+			}
+		}
+
+		p.consume(RIGHT_PAREN, "Expect ')' after class pattern fields.")
+		return &ClassPattern{&Variable{name}, paren, args}
+	}
+
+	p.panic(&ParseError{p.peek(), "Expect pattern."})
+
+	return nil
+}
+
+func (p *Parser) listPattern() Pattern {
+	bracket := p.previous()
+	elements := []Pattern{}
+	var rest *BindPattern
+
+	for !p.check(RIGHT_BRACKET) && !p.isAtEnd() {
+		if p.match(DOT_DOT_DOT) {
+			rest = &BindPattern{p.consume(IDENTIFIER, "Expect name after '...' in pattern.")}
+
+			if !p.check(RIGHT_BRACKET) {
+				p.panic(&ParseError{p.peek(), "Rest pattern must be the last one."})
+			}
+			break
+		}
+
+		elements = append(elements, p.pattern())
+
+		if !p.match(COMMA) {
+			break
+		}
+	}
+
+	p.consume(RIGHT_BRACKET, "Expect ']' after list pattern.")
+
+	return &ListPattern{bracket, elements, rest}
+}
+
+func (p *Parser) mapPattern() Pattern {
+	brace := p.previous()
+	keys := []string{}
+	values := []Pattern{}
+
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		var key Token
+
+		if p.match(IDENTIFIER, STRING) {
+			key = p.previous()
+		} else {
+			p.panic(&ParseError{p.peek(), "Expect key in map pattern."})
+		}
+
+		var value Pattern
+
+		if p.match(COLON) {
+			value = p.pattern()
+		} else if key.typ == IDENTIFIER {
+			// {name} is a shorthand for {name: name}
+			value = &BindPattern{key}
+		} else {
+			p.panic(&ParseError{p.peek(), "Expect ':' after string key in map pattern."})
+		}
+
+		// identifiers and strings both keep their text as literal
+		keys = append(keys, key.literal.(string))
+		values = append(values, value)
+
+		if !p.match(COMMA) {
+			break
 		}
 	}
 
+	p.consume(RIGHT_BRACE, "Expect '}' after map pattern.")
+
+	return &MapPattern{brace, keys, values}
+}
+
+func (p *Parser) lambda() Expr {
+	name := p.previous()
+
+	p.consume(LEFT_PAREN, "Expect '(' before lambda arguments.")
+
+	args := p.parameters("lambda")
+
 	p.consume(RIGHT_PAREN, "Expect ')' after lambda arguments.")
+	returns := p.annotation()
 	p.consume(LEFT_BRACE, "Expect '{' before lambda body.")
 
-	body := p.block()
+	body, generator := p.functionBody()
 
-	return &Lambda{name, args, body}
+	return &Lambda{name, args, body, generator, returns}
 }
 
 func (p *Parser) previous() Token {
@@ -540,6 +1061,15 @@ func (p *Parser) peek() Token {
 	return p.tokens[p.current]
 }
 
+// peekAt returns token offset positions after the current one.
+func (p *Parser) peekAt(offset int) Token {
+	if p.current+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+
+	return p.tokens[p.current+offset]
+}
+
 func (p *Parser) isAtEnd() bool {
 	return p.peek().typ == EOF
 }
@@ -599,7 +1129,7 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().typ {
-		case CLASS, FOR, FUN, IF, PRINT, RETURN, VAR, WHILE, ENV:
+		case CLASS, CONST, TRAIT, FOR, FUN, IF, PRINT, RETURN, VAR, WHILE, ENV:
 			return
 		}
 
@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 func TestSimpleParser(t *testing.T) {
 	s := newScanner([]byte("print 1;"))
@@ -11,3 +14,21 @@ func TestSimpleParser(t *testing.T) {
 		t.Fatal("cant parse")
 	}
 }
+
+func TestAstJSON(t *testing.T) {
+	tokens, _ := newScanner([]byte("var a = 1;\nprint -a;")).scan()
+	stmts, _ := newParser(tokens).parse()
+
+	dump, err := json.Marshal(&AstJSON{stmts: stmts})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"initializer":{"node":"Literal","token":{"column":9,"lexeme":"1","line":1,"type":"NUMBER"},"value":1},"name":{"column":5,"lexeme":"a","line":1,"type":"IDENTIFIER"},"node":"VarStmt"},` +
+		`{"node":"PrintStmt","val":{"node":"Unary","op":{"column":7,"lexeme":"-","line":2,"type":"MINUS"},"right":{"name":{"column":8,"lexeme":"a","line":2,"type":"IDENTIFIER"},"node":"Variable"}}}]`
+
+	if string(dump) != expected {
+		t.Fatalf("expected %s, got %s", expected, dump)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import "fmt"
+
+// Pattern describes shape of a value in "match" cases and
+// destructuring. Matching a value binds variables to its parts.
+type Pattern interface {
+	pattern()
+}
+
+// LiteralPattern matches values equal to the literal.
+type LiteralPattern struct {
+	token Token
+	value any
+}
+
+// BindPattern matches anything and binds it to name, "_" binds nothing.
+type BindPattern struct {
+	name Token
+}
+
+// ListPattern matches lists with exactly as many elements, or
+// at least as many when rest collects the remaining ones.
+type ListPattern struct {
+	bracket  Token
+	elements []Pattern
+	rest     *BindPattern
+}
+
+// MapPattern matches maps having all the keys and objects
+// having all the properties, other keys are ignored.
+type MapPattern struct {
+	brace  Token
+	keys   []string
+	values []Pattern
+}
+
+// ClassPattern matches instances of the class. Arguments are
+// matched against fields named after "init" parameters.
+type ClassPattern struct {
+	class *Variable
+	paren Token
+	args  []Pattern
+}
+
+func (l *LiteralPattern) pattern() {}
+func (b *BindPattern) pattern()    {}
+func (l *ListPattern) pattern()    {}
+func (m *MapPattern) pattern()     {}
+func (c *ClassPattern) pattern()   {}
+
+// patternToken returns token errors about pattern are reported at.
+func patternToken(pattern Pattern) Token {
+	switch p := pattern.(type) {
+	case *LiteralPattern:
+		return p.token
+	case *BindPattern:
+		return p.name
+	case *ListPattern:
+		return p.bracket
+	case *MapPattern:
+		return p.brace
+	case *ClassPattern:
+		return p.class.name
+	}
+
+	return Token{}
+}
+
+// bindings returns variables bound by pattern in the order they appear.
+func bindings(pattern Pattern) []Token {
+	switch p := pattern.(type) {
+	case *BindPattern:
+		if p.name.lexeme == "_" {
+			return []Token{}
+		}
+		return []Token{p.name}
+	case *ListPattern:
+		names := []Token{}
+		for _, element := range p.elements {
+			names = append(names, bindings(element)...)
+		}
+		if p.rest != nil {
+			names = append(names, bindings(p.rest)...)
+		}
+		return names
+	case *MapPattern:
+		names := []Token{}
+		for _, value := range p.values {
+			names = append(names, bindings(value)...)
+		}
+		return names
+	case *ClassPattern:
+		names := []Token{}
+		for _, arg := range p.args {
+			names = append(names, bindings(arg)...)
+		}
+		return names
+	}
+
+	return []Token{}
+}
+
+// classes returns class names patterns refer to,
+// they are resolved outside of the case scope.
+func classes(pattern Pattern) []*Variable {
+	switch p := pattern.(type) {
+	case *ListPattern:
+		vars := []*Variable{}
+		for _, element := range p.elements {
+			vars = append(vars, classes(element)...)
+		}
+		return vars
+	case *MapPattern:
+		vars := []*Variable{}
+		for _, value := range p.values {
+			vars = append(vars, classes(value)...)
+		}
+		return vars
+	case *ClassPattern:
+		vars := []*Variable{p.class}
+		for _, arg := range p.args {
+			vars = append(vars, classes(arg)...)
+		}
+		return vars
+	}
+
+	return []*Variable{}
+}
+
+// destructure binds variables of pattern to parts of
+// value, value not matching the pattern is an error.
+func (i *Interpreter) destructure(pattern Pattern, value any) map[string]any {
+	bound := map[string]any{}
+
+	if !i.matches(pattern, value, bound) && i.thrown == nil {
+		i.throw(&RuntimeError{patternToken(pattern), fmt.Sprintf("Can't destructure %s.", i.stringify(value))})
+	}
+
+	return bound
+}
+
+// matches reports whether value has the shape of pattern,
+// bound variables are collected in bound.
+func (i *Interpreter) matches(pattern Pattern, value any, bound map[string]any) bool {
+	switch p := pattern.(type) {
+	case *LiteralPattern:
+		return i.equals(p.value, value)
+	case *BindPattern:
+		if p.name.lexeme != "_" {
+			bound[p.name.lexeme] = value
+		}
+		return true
+	case *ListPattern:
+		return i.matchesList(p, value, bound)
+	case *MapPattern:
+		return i.matchesMap(p, value, bound)
+	case *ClassPattern:
+		return i.matchesInstance(p, value, bound)
+	}
+
+	return false
+}
+
+func (i *Interpreter) matchesList(p *ListPattern, value any, bound map[string]any) bool {
+	list, ok := value.(*List)
+
+	if !ok {
+		return false
+	}
+
+	items := list.items()
+
+	if len(items) < len(p.elements) || (p.rest == nil && len(items) != len(p.elements)) {
+		return false
+	}
+
+	for idx, element := range p.elements {
+		if !i.matches(element, items[idx], bound) {
+			return false
+		}
+	}
+
+	if p.rest != nil {
+		return i.matches(p.rest, newList(items[len(p.elements):]...), bound)
+	}
+
+	return true
+}
+
+func (i *Interpreter) matchesMap(p *MapPattern, value any, bound map[string]any) bool {
+	if _, ok := value.(Object); !ok {
+		return false
+	}
+
+	for idx, key := range p.keys {
+		val, ok := i.property(value, key)
+
+		if !ok || i.thrown != nil || !i.matches(p.values[idx], val, bound) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i *Interpreter) matchesInstance(p *ClassPattern, value any, bound map[string]any) bool {
+	class, ok := i.evaluate(p.class).(*Class)
+
+	if i.thrown != nil {
+		return false
+	}
+
+	if !ok {
+		i.throw(&RuntimeError{p.class.name, fmt.Sprintf("%s is not a class.", p.class.name.lexeme)})
+		return false
+	}
+
+	var params []Param
+
+	if init, ok := class.methods["init"]; ok {
+		params = init.args
+	}
+
+	if len(p.args) > len(params) {
+		i.throw(&RuntimeError{p.paren, fmt.Sprintf(
+			"Pattern has %d fields but %s init takes %s.", len(p.args), class.name, arguments(len(params)),
+		)})
+		return false
+	}
+
+	instance, ok := value.(*ClassInstance)
+
+	if !ok || instance.klass != class {
+		return false
+	}
+
+	for idx, arg := range p.args {
+		field, ok := instance.get(params[idx].name.lexeme)
+
+		if !ok || !i.matches(arg, field, bound) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// property looks up map key or object property the way "." does.
+func (i *Interpreter) property(object any, key string) (any, bool) {
+	if m, ok := object.(*Map); ok {
+		return m.lookup(key)
+	}
+
+	if klass := members(object); klass != nil {
+		if getter, ok := klass.getters[key]; ok {
+			return getter.bind(object).call(i), true
+		}
+	}
+
+	return object.(Object).get(key)
+}
@@ -0,0 +1,98 @@
+package main
+
+// stmtLine returns line statement starts at, 0 when
+// statement has no tokens to tell, like "1;".
+func stmtLine(stmt Stmt) int {
+	switch s := stmt.(type) {
+	case *PrintStmt:
+		return s.keyword.line
+	case *ExprStmt:
+		return exprLine(s.expr)
+	case *VarStmt:
+		return s.name.line
+	case *DestructureStmt:
+		return patternToken(s.pattern).line
+	case *BlockStmt:
+		for _, stmt := range s.stmts {
+			if line := stmtLine(stmt); line != 0 {
+				return line
+			}
+		}
+	case *IfStmt:
+		return s.name.line
+	case *WhileStmt:
+		if line := exprLine(s.cond); line != 0 {
+			return line
+		}
+		return stmtLine(s.body)
+	case *ForInStmt:
+		return s.keyword.line
+	case *BreakStmt:
+		return s.keyword.line
+	case *ContinueStmt:
+		return s.keyword.line
+	case *FunStmt:
+		return s.name.line
+	case *ReturnStmt:
+		return s.keyword.line
+	case *YieldStmt:
+		return s.keyword.line
+	case *ClassStmt:
+		return s.name.line
+	case *TraitStmt:
+		return s.name.line
+	}
+
+	return 0
+}
+
+// exprLine returns line of the first token of expression,
+// 0 when expression has no tokens.
+func exprLine(expr Expr) int {
+	switch e := expr.(type) {
+	case *Literal:
+		return e.token.line
+	case *Unary:
+		return e.op.line
+	case *Binary:
+		return firstLine(exprLine(e.left), e.op.line)
+	case *Grouping:
+		return exprLine(e.expression)
+	case *Variable:
+		return e.name.line
+	case *Assign:
+		return e.variable.line
+	case *Logical:
+		return firstLine(exprLine(e.left), e.operator.line)
+	case *Call:
+		return firstLine(exprLine(e.callee), e.paren.line)
+	case *Lambda:
+		return e.name.line
+	case *Spread:
+		return e.ellipsis.line
+	case *Get:
+		return firstLine(exprLine(e.obj), e.name.line)
+	case *Set:
+		return firstLine(exprLine(e.obj), e.name.line)
+	case *This:
+		return e.keyword.line
+	case *Spawn:
+		return e.keyword.line
+	case *Match:
+		return e.keyword.line
+	case *ListExpr:
+		return e.bracket.line
+	case *Destructure:
+		return firstLine(patternToken(e.pattern).line, e.equal.line)
+	}
+
+	return 0
+}
+
+func firstLine(line int, fallback int) int {
+	if line != 0 {
+		return line
+	}
+
+	return fallback
+}
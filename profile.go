@@ -0,0 +1,486 @@
+package main
+
+import (
+	"cmp"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Profile is a tree of Lox calls with time spent on each line
+// and number of calls. Time between two statements is charged
+// to the line of the first one, so time spent in natives goes to
+// the line calling them. Tasks and generators add their calls to
+// the same tree under the function that started them, their time
+// adds up with the caller's, so total may exceed the wall time.
+type Profile struct {
+	// script path written to the pprof file
+	path  string
+	root  *callNode
+	start time.Time
+	mu    sync.Mutex
+}
+
+// callNode is a function called from a particular line
+// of its caller, recursion adds a node for every level.
+type callNode struct {
+	name string
+	// line function is declared at
+	line     int
+	parent   *callNode
+	callLine int
+	children map[callKey]*callNode
+	lines    map[int]time.Duration
+	calls    int64
+}
+
+type callKey struct {
+	name     string
+	line     int
+	callLine int
+}
+
+// profiler is the position of one interpreter in the Profile.
+type profiler struct {
+	profile *Profile
+	node    *callNode
+	line    int
+	last    time.Time
+}
+
+func newProfile(path string) *Profile {
+	return &Profile{
+		path:  path,
+		root:  newCallNode("(script)", 1, nil, 0),
+		start: time.Now(),
+	}
+}
+
+func newCallNode(name string, line int, parent *callNode, callLine int) *callNode {
+	return &callNode{
+		name:     name,
+		line:     line,
+		parent:   parent,
+		callLine: callLine,
+		children: map[callKey]*callNode{},
+		lines:    map[int]time.Duration{},
+	}
+}
+
+func newProfiler(profile *Profile) *profiler {
+	return &profiler{profile, profile.root, 1, time.Now()}
+}
+
+// fork starts profiling another interpreter at the current position.
+func (p *profiler) fork() *profiler {
+	if p == nil {
+		return nil
+	}
+
+	p.profile.mu.Lock()
+	defer p.profile.mu.Unlock()
+
+	return &profiler{p.profile, p.node, p.line, time.Now()}
+}
+
+// tick charges time since the last event to the current line,
+// caller holds the lock.
+func (p *profiler) tick() {
+	now := time.Now()
+	p.node.lines[p.line] += now.Sub(p.last)
+	p.last = now
+}
+
+func (p *profiler) statement(stmt Stmt) {
+	p.profile.mu.Lock()
+	defer p.profile.mu.Unlock()
+
+	p.tick()
+
+	if line := stmtLine(stmt); line != 0 {
+		p.line = line
+	}
+}
+
+func (p *profiler) enter(f *Function) {
+	p.profile.mu.Lock()
+	defer p.profile.mu.Unlock()
+
+	p.tick()
+
+	name := f.label()
+	key := callKey{name, f.name.line, p.line}
+	node, ok := p.node.children[key]
+
+	if !ok {
+		node = newCallNode(name, f.name.line, p.node, p.line)
+		p.node.children[key] = node
+	}
+
+	node.calls++
+	p.node = node
+	p.line = f.name.line
+}
+
+func (p *profiler) exit() {
+	p.profile.mu.Lock()
+	defer p.profile.mu.Unlock()
+
+	p.tick()
+
+	p.line = p.node.callLine
+	p.node = p.node.parent
+}
+
+// flush charges time spent on the current line so far.
+func (p *profiler) flush() {
+	p.profile.mu.Lock()
+	defer p.profile.mu.Unlock()
+
+	p.tick()
+}
+
+// idle drops time spent waiting, like suspended generator
+// waiting for the next value, so it's not charged to any line.
+func (p *profiler) idle() {
+	if p == nil {
+		return
+	}
+
+	p.profile.mu.Lock()
+	defer p.profile.mu.Unlock()
+
+	p.last = time.Now()
+}
+
+// walk calls visit for every node of the tree.
+func (n *callNode) walk(visit func(*callNode)) {
+	visit(n)
+
+	for _, child := range n.children {
+		child.walk(visit)
+	}
+}
+
+// stack returns functions from n to the root, each with the
+// line it was at: the given line for n and call lines for callers.
+func (n *callNode) stack(line int) []profileFrame {
+	frames := []profileFrame{}
+
+	for node := n; node != nil; node = node.parent {
+		frames = append(frames, profileFrame{node.name, node.line, line})
+		line = node.callLine
+	}
+
+	return frames
+}
+
+type profileFrame struct {
+	name string
+	// line function is declared at
+	start int
+	line  int
+}
+
+// writePprof writes profile in gzipped pprof protobuf format
+// with "calls" and "time" sample types.
+func (p *Profile) writePprof(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	enc := newPprofEncoder()
+
+	calls := enc.str("calls")
+	count := enc.str("count")
+	timeType := enc.str("time")
+	nanoseconds := enc.str("nanoseconds")
+
+	enc.valueType(1, calls, count)
+	enc.valueType(1, timeType, nanoseconds)
+
+	p.root.walk(func(node *callNode) {
+		if node.calls > 0 {
+			enc.sample(node.stack(node.line), node.calls, 0)
+		}
+
+		for _, line := range sortedLines(node) {
+			enc.sample(node.stack(line), 0, int64(node.lines[line]))
+		}
+	})
+
+	enc.functions(enc.str(p.path))
+	enc.int64(9, p.start.UnixNano())
+	enc.int64(10, int64(time.Since(p.start)))
+	enc.valueType(11, timeType, nanoseconds)
+	enc.int64(12, 1)
+	enc.int64(14, timeType)
+	enc.strings()
+
+	gz := gzip.NewWriter(w)
+
+	if _, err := gz.Write(enc.protobuf); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func sortedLines(node *callNode) []int {
+	lines := []int{}
+	for line := range node.lines {
+		lines = append(lines, line)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// profileSummary is time and calls of a function or a line.
+type profileSummary struct {
+	name  string
+	flat  time.Duration
+	cum   time.Duration
+	calls int64
+}
+
+// writeText writes a table of functions sorted by their own
+// time and a table of the slowest lines.
+func (p *Profile) writeText(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	functions := map[string]*profileSummary{}
+	lines := map[string]*profileSummary{}
+	var total time.Duration
+
+	summary := func(summaries map[string]*profileSummary, name string) *profileSummary {
+		if _, ok := summaries[name]; !ok {
+			summaries[name] = &profileSummary{name: name}
+		}
+		return summaries[name]
+	}
+
+	p.root.walk(func(node *callNode) {
+		fun := summary(functions, fmt.Sprintf("%s %s:%d", node.name, p.path, node.line))
+		fun.calls += node.calls
+
+		for line, spent := range node.lines {
+			total += spent
+			fun.flat += spent
+			summary(lines, fmt.Sprintf("%s:%d %s", p.path, line, node.name)).flat += spent
+
+			// recursive calls count towards cumulative time once
+			seen := map[string]bool{}
+			for _, frame := range node.stack(line) {
+				name := fmt.Sprintf("%s %s:%d", frame.name, p.path, frame.start)
+				if !seen[name] {
+					seen[name] = true
+					summary(functions, name).cum += spent
+				}
+			}
+		}
+	})
+
+	percent := func(d time.Duration) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(d) * 100 / float64(total)
+	}
+
+	fmt.Fprintf(w, "Total: %s\n\n", total)
+	fmt.Fprintf(w, "%12s %7s %12s %7s %10s  %s\n", "flat", "flat%", "cum", "cum%", "calls", "function")
+
+	for _, fun := range sortedSummaries(functions) {
+		fmt.Fprintf(w, "%12s %6.2f%% %12s %6.2f%% %10d  %s\n", fun.flat, percent(fun.flat), fun.cum, percent(fun.cum), fun.calls, fun.name)
+	}
+
+	fmt.Fprintf(w, "\n%12s %7s  %s\n", "flat", "flat%", "line")
+
+	for _, line := range sortedSummaries(lines) {
+		_, err := fmt.Fprintf(w, "%12s %6.2f%%  %s\n", line.flat, percent(line.flat), line.name)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sortedSummaries sorts by own time, slowest first.
+func sortedSummaries(summaries map[string]*profileSummary) []*profileSummary {
+	sorted := []*profileSummary{}
+	for _, summary := range summaries {
+		sorted = append(sorted, summary)
+	}
+
+	slices.SortFunc(sorted, func(a, b *profileSummary) int {
+		if c := cmp.Compare(b.flat, a.flat); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.name, b.name)
+	})
+
+	return sorted
+}
+
+// pprofEncoder builds Profile message of pprof's profile.proto
+// by hand, so glox doesn't depend on protobuf libraries.
+type pprofEncoder struct {
+	protobuf
+	strtab    []string
+	strIdx    map[string]int64
+	funcs     map[profileFunction]uint64
+	funcOrder []profileFunction
+	locs      map[profileLocation]uint64
+	locOrder  []profileLocation
+}
+
+type profileFunction struct {
+	name  string
+	start int
+}
+
+type profileLocation struct {
+	function uint64
+	line     int
+}
+
+func newPprofEncoder() *pprofEncoder {
+	return &pprofEncoder{
+		strtab: []string{""},
+		strIdx: map[string]int64{"": 0},
+		funcs:  map[profileFunction]uint64{},
+		locs:   map[profileLocation]uint64{},
+	}
+}
+
+// str returns index of s in the string table.
+func (e *pprofEncoder) str(s string) int64 {
+	if idx, ok := e.strIdx[s]; ok {
+		return idx
+	}
+
+	idx := int64(len(e.strtab))
+	e.strtab = append(e.strtab, s)
+	e.strIdx[s] = idx
+	return idx
+}
+
+func (e *pprofEncoder) location(frame profileFrame) uint64 {
+	fun := profileFunction{frame.name, frame.start}
+	funID, ok := e.funcs[fun]
+
+	if !ok {
+		funID = uint64(len(e.funcOrder) + 1)
+		e.funcs[fun] = funID
+		e.funcOrder = append(e.funcOrder, fun)
+	}
+
+	loc := profileLocation{funID, frame.line}
+	locID, ok := e.locs[loc]
+
+	if !ok {
+		locID = uint64(len(e.locOrder) + 1)
+		e.locs[loc] = locID
+		e.locOrder = append(e.locOrder, loc)
+
+		line := &protobuf{}
+		line.uint64(1, funID)
+		line.int64(2, int64(frame.line))
+
+		location := &protobuf{}
+		location.uint64(1, locID)
+		location.message(4, line)
+
+		e.message(4, location)
+	}
+
+	return locID
+}
+
+// sample adds Sample with frames from the leaf to the root.
+func (e *pprofEncoder) sample(frames []profileFrame, calls int64, nanos int64) {
+	ids := []uint64{}
+	for _, frame := range frames {
+		ids = append(ids, e.location(frame))
+	}
+
+	sample := &protobuf{}
+	sample.packed(1, ids)
+	sample.packed(2, []uint64{uint64(calls), uint64(nanos)})
+
+	e.message(2, sample)
+}
+
+func (e *pprofEncoder) functions(filename int64) {
+	for idx, fun := range e.funcOrder {
+		function := &protobuf{}
+		function.uint64(1, uint64(idx+1))
+		function.int64(2, e.str(fun.name))
+		function.int64(3, e.str(fun.name))
+		function.int64(4, filename)
+		function.int64(5, int64(fun.start))
+
+		e.message(5, function)
+	}
+}
+
+func (e *pprofEncoder) valueType(field int, typ int64, unit int64) {
+	value := &protobuf{}
+	value.int64(1, typ)
+	value.int64(2, unit)
+
+	e.message(field, value)
+}
+
+func (e *pprofEncoder) strings() {
+	for _, s := range e.strtab {
+		e.bytes(6, []byte(s))
+	}
+}
+
+// protobuf is a message in protobuf wire format.
+type protobuf []byte
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		*b = append(*b, byte(x)|0x80)
+		x >>= 7
+	}
+	*b = append(*b, byte(x))
+}
+
+func (b *protobuf) tag(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) uint64(field int, x uint64) {
+	b.tag(field, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	packed := &protobuf{}
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(field, *packed)
+}
+
+func (b *protobuf) message(field int, m *protobuf) {
+	b.bytes(field, *m)
+}
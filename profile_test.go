@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	source := `
+		fun fib(n) {
+			if (n < 2) return n;
+			return fib(n - 1) + fib(n - 2);
+		}
+		var double = fun (x) { return x * 2; };
+		print double(fib(5));
+	`
+
+	profile := newProfile("fib.lox")
+	interpreter := testInterpreter(io.Discard)
+	interpreter.profiler = newProfiler(profile)
+
+	runScript(t, interpreter, source)
+
+	text := &strings.Builder{}
+	if err := profile.writeText(text); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := map[string]string{}
+	for _, line := range strings.Split(text.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) == 7 {
+			calls[fields[5]+" "+fields[6]] = fields[4]
+		}
+	}
+
+	expected := map[string]string{"fib fib.lox:2": "15", "(fn) fib.lox:6": "1", "(script) fib.lox:1": "0"}
+
+	for name, count := range expected {
+		if calls[name] != count {
+			t.Errorf("expected %s to be called %s times, got %q in\n%s", name, count, calls[name], text)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := profile.writePprof(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pprof := decodePprof(t, data)
+
+	types := fmt.Sprint(pprof.sampleTypes)
+	if types != "[calls/count time/nanoseconds]" {
+		t.Errorf("expected calls and time sample types, got %s", types)
+	}
+
+	sampled := map[string]uint64{}
+	var nanos uint64
+
+	for _, sample := range pprof.samples {
+		if root := sample.stack[len(sample.stack)-1]; !strings.HasPrefix(root, "(script) fib.lox:") {
+			t.Errorf("expected stack to start in script, got %v", sample.stack)
+		}
+
+		sampled[sample.stack[0]] += sample.values[0]
+		nanos += sample.values[1]
+	}
+
+	// calls are counted at function declaration line
+	counted := map[string]uint64{"fib fib.lox:2": 15, "(fn) fib.lox:6": 1}
+
+	for location, count := range counted {
+		if sampled[location] != count {
+			t.Errorf("expected %d calls at %s, got %d", count, location, sampled[location])
+		}
+	}
+
+	// time is spent on lines with statements
+	for _, location := range []string{"fib fib.lox:3", "fib fib.lox:4", "(fn) fib.lox:6", "(script) fib.lox:7"} {
+		if _, ok := sampled[location]; !ok {
+			t.Errorf("expected samples at %s, got %v", location, sampled)
+		}
+	}
+
+	if nanos == 0 {
+		t.Error("expected time to be sampled")
+	}
+}
+
+// pprofProfile is the part of decoded profile.proto the test checks.
+type pprofProfile struct {
+	// type/unit
+	sampleTypes []string
+	samples     []pprofSample
+}
+
+type pprofSample struct {
+	// "function file:line" from the leaf to the root
+	stack  []string
+	values []uint64
+}
+
+// decodePprof reads profile.proto message, independently of the encoder.
+func decodePprof(t *testing.T, data []byte) pprofProfile {
+	fields := decodeProtobuf(t, data)
+
+	strs := []string{}
+	for _, str := range fields[6] {
+		strs = append(strs, string(str.([]byte)))
+	}
+
+	str := func(idx any) string {
+		return strs[idx.(uint64)]
+	}
+
+	functions := map[uint64]string{}
+	for _, function := range fields[5] {
+		f := decodeProtobuf(t, function.([]byte))
+		functions[f[1][0].(uint64)] = str(f[2][0]) + " " + str(f[4][0])
+	}
+
+	locations := map[uint64]string{}
+	for _, location := range fields[4] {
+		l := decodeProtobuf(t, location.([]byte))
+		line := decodeProtobuf(t, l[4][0].([]byte))
+		locations[l[1][0].(uint64)] = fmt.Sprintf("%s:%d", functions[line[1][0].(uint64)], line[2][0])
+	}
+
+	profile := pprofProfile{}
+
+	for _, typ := range fields[1] {
+		vt := decodeProtobuf(t, typ.([]byte))
+		profile.sampleTypes = append(profile.sampleTypes, str(vt[1][0])+"/"+str(vt[2][0]))
+	}
+
+	for _, sample := range fields[2] {
+		s := decodeProtobuf(t, sample.([]byte))
+		decoded := pprofSample{values: decodePacked(t, s[2][0].([]byte))}
+
+		for _, id := range decodePacked(t, s[1][0].([]byte)) {
+			decoded.stack = append(decoded.stack, locations[id])
+		}
+
+		profile.samples = append(profile.samples, decoded)
+	}
+
+	return profile
+}
+
+// decodeProtobuf returns values of message fields by field number:
+// varints as uint64 and length delimited fields as []byte.
+func decodeProtobuf(t *testing.T, data []byte) map[int][]any {
+	t.Helper()
+
+	fields := map[int][]any{}
+
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatal("malformed field key")
+		}
+		data = data[n:]
+
+		field := int(key >> 3)
+
+		switch key & 7 {
+		case 0:
+			val, n := binary.Uvarint(data)
+			if n <= 0 {
+				t.Fatal("malformed varint")
+			}
+			fields[field] = append(fields[field], val)
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				t.Fatal("malformed length delimited field")
+			}
+			fields[field] = append(fields[field], data[n:n+int(size)])
+			data = data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+
+	return fields
+}
+
+func decodePacked(t *testing.T, data []byte) []uint64 {
+	t.Helper()
+
+	vals := []uint64{}
+
+	for len(data) > 0 {
+		val, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatal("malformed packed varint")
+		}
+		vals = append(vals, val)
+		data = data[n:]
+	}
+
+	return vals
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Go regexp implements RE2 so matching time is linear in the size
+// of the input, it is safe to compile patterns we don't control.
+type Regex struct {
+	re *regexp.Regexp
+}
+
+const regexCacheSize = 256
+
+type regexCache struct {
+	mu       sync.Mutex
+	compiled map[string]*regexp.Regexp
+}
+
+var regexes = &regexCache{compiled: map[string]*regexp.Regexp{}}
+
+func (rc *regexCache) compile(pattern string) (*regexp.Regexp, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if re, ok := rc.compiled[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// patterns may come from outside so don't let
+	// the cache grow without bounds
+	if len(rc.compiled) >= regexCacheSize {
+		clear(rc.compiled)
+	}
+
+	rc.compiled[pattern] = re
+
+	return re, nil
+}
+
+// regex(pattern)
+func compileRegex(i *Interpreter, args ...any) (any, error) {
+	pattern, err := stringArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexes.compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Regex{re}, nil
+}
+
+func (r *Regex) String() string {
+	return fmt.Sprintf("/%s/", r.re)
+}
+
+func (r *Regex) get(name string) (any, bool) {
+	switch name {
+	case "pattern":
+		return r.re.String(), true
+	case "test":
+		return r.method("test", 1, func(args []string) any {
+			return r.re.MatchString(args[0])
+		}), true
+	case "find":
+		return r.method("find", 1, func(args []string) any {
+			loc := r.re.FindStringIndex(args[0])
+			if loc == nil {
+				return nil
+			}
+			return args[0][loc[0]:loc[1]]
+		}), true
+	case "findAll":
+		return r.method("findAll", 1, func(args []string) any {
+			found := []any{}
+			for _, match := range r.re.FindAllString(args[0], -1) {
+				found = append(found, match)
+			}
+			return newList(found...)
+		}), true
+	case "groups":
+		return r.method("groups", 1, func(args []string) any {
+			loc := r.re.FindStringSubmatchIndex(args[0])
+			if loc == nil {
+				return nil
+			}
+			return newList(r.submatches(args[0], loc)...)
+		}), true
+	case "namedGroups":
+		return r.method("namedGroups", 1, func(args []string) any {
+			loc := r.re.FindStringSubmatchIndex(args[0])
+			if loc == nil {
+				return nil
+			}
+			groups := newMap()
+			submatches := r.submatches(args[0], loc)
+			for idx, name := range r.re.SubexpNames() {
+				if name != "" {
+					groups.put(name, submatches[idx])
+				}
+			}
+			return groups
+		}), true
+	case "replace":
+		// replacement may reference groups with $1 or ${name}
+		return r.method("replace", 2, func(args []string) any {
+			return r.re.ReplaceAllString(args[0], args[1])
+		}), true
+	case "split":
+		return r.method("split", 1, func(args []string) any {
+			parts := []any{}
+			for _, part := range r.re.Split(args[0], -1) {
+				parts = append(parts, part)
+			}
+			return newList(parts...)
+		}), true
+	}
+
+	return nil, false
+}
+
+// submatches returns matched groups, groups that didn't participate are nil.
+func (r *Regex) submatches(str string, loc []int) []any {
+	groups := []any{}
+	for idx := 0; idx < len(loc); idx += 2 {
+		if loc[idx] < 0 {
+			groups = append(groups, nil)
+		} else {
+			groups = append(groups, str[loc[idx]:loc[idx+1]])
+		}
+	}
+	return groups
+}
+
+// method wraps regex operation that accepts only strings.
+func (r *Regex) method(name string, arity int, fn func(args []string) any) *NativeFun {
+	return newNative(name, arity, func(i *Interpreter, args ...any) (any, error) {
+		strs := []string{}
+		for _, arg := range args {
+			str, err := stringArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			strs = append(strs, str)
+		}
+		return fn(strs), nil
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegexCache(t *testing.T) {
+	cache := &regexCache{compiled: map[string]*regexp.Regexp{}}
+
+	first, err := cache.compile(`\d+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := cache.compile(`\d+`)
+
+	if first != second {
+		t.Error("expected the same pattern to be compiled once")
+	}
+
+	for n := range regexCacheSize * 2 {
+		if _, err := cache.compile(fmt.Sprintf("a{%d}", n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(cache.compiled) > regexCacheSize {
+		t.Errorf("expected at most %d cached patterns, got %d", regexCacheSize, len(cache.compiled))
+	}
+}
+
+func TestRegexMethods(t *testing.T) {
+	value, err := compileRegex(nil, `(\w)(\d)?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(name string, args ...any) (any, error) {
+		method, ok := value.(*Regex).get(name)
+		if !ok {
+			t.Fatalf("no %s method", name)
+		}
+		return method.(*NativeFun).fn(nil, args...)
+	}
+
+	// groups that didn't participate are nil
+	groups, _ := call("groups", "a")
+	if stringify(groups) != "[a, a, nil]" {
+		t.Errorf("expected [a, a, nil], got %s", stringify(groups))
+	}
+
+	if _, err := call("test", 1.0); err == nil {
+		t.Error("expected error for non string argument")
+	}
+
+	if _, err := compileRegex(nil, `(`); err == nil || !strings.Contains(err.Error(), "missing closing )") {
+		t.Errorf("expected invalid pattern error, got %v", err)
+	}
+}
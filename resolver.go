@@ -1,30 +1,55 @@
 package main
 
-type Scope map[string]bool
+import (
+	"errors"
+	"fmt"
+)
+
+// Scope maps names of local variables to their slots in
+// the Environment. Slots are given in declaration order.
+type Scope struct {
+	slots     map[string]int
+	defined   map[string]bool
+	constants map[string]bool
+	size      int
+}
 
 type Resolver struct {
 	interpreter *Interpreter
 	scopes      Stack[Scope]
+	errors      []error
+	// how deep we are in loops, functions and classes, to
+	// check "break", "continue", "return" and "this" placement
+	loops     int
+	functions int
+	classes   int
+	// resolving "init" method, which can't return a value
+	initializer bool
+	// resolving function body where returned calls can run
+	// on the trampoline, generators drop returned values
+	tails bool
 }
 
 type ResolveError struct {
-	msg string
+	token Token
+	msg   string
 }
 
 func (r *ResolveError) Error() string {
-	return r.msg
+	return fmt.Sprintf("ResolveError [%d][%s]: %s", r.token.line, r.token.typ, r.msg)
 }
 
 func newResolver(i *Interpreter) *Resolver {
-	return &Resolver{i, NewStack[Scope]()}
+	return &Resolver{i, NewStack[Scope](), []error{}, 0, 0, 0, false, false}
 }
 
 func (r *Resolver) resolveStmts(stmts ...Stmt) error {
 	for _, stmt := range stmts {
+		r.interpreter.coverage.instrument(stmt)
 		stmt.accept(r)
 	}
 
-	return nil
+	return errors.Join(r.errors...)
 }
 
 func (r *Resolver) resolveExprs(exprs ...Expr) error {
@@ -35,8 +60,12 @@ func (r *Resolver) resolveExprs(exprs ...Expr) error {
 	return nil
 }
 
+func (r *Resolver) error(token Token, msg string) {
+	r.errors = append(r.errors, &ResolveError{token, msg})
+}
+
 func (r *Resolver) beginScope() {
-	r.scopes.Push(map[string]bool{})
+	r.scopes.Push(Scope{map[string]int{}, map[string]bool{}, map[string]bool{}, 0})
 }
 
 func (r *Resolver) endScope() {
@@ -44,37 +73,84 @@ func (r *Resolver) endScope() {
 }
 
 func (r *Resolver) declare(token Token) {
+	if r.scopes.Empty() {
+		return
+	}
+
+	scope := r.scopes.Pop()
+
+	if scope.constants[token.lexeme] {
+		r.error(token, fmt.Sprintf("Can't redeclare constant '%s'.", token.lexeme))
+	}
+
+	// redeclared variable gets a new slot as well,
+	// closures keep referencing the old one
+	delete(scope.constants, token.lexeme)
+	scope.slots[token.lexeme] = scope.size
+	scope.defined[token.lexeme] = false
+	scope.size++
+	r.scopes.Push(scope)
+}
+
+// constant marks declared local variable as constant.
+func (r *Resolver) constant(token Token) {
 	if !r.scopes.Empty() {
-		r.scopes.Peek()[token.lexeme] = false
+		r.scopes.Peek().constants[token.lexeme] = true
 	}
 }
 
 func (r *Resolver) define(token Token) {
 	if !r.scopes.Empty() {
-		r.scopes.Peek()[token.lexeme] = true
+		r.scopes.Peek().defined[token.lexeme] = true
 	}
 }
 
-func (r *Resolver) visitBlockStmt(b *BlockStmt) {
+func (r *Resolver) visitBlockStmt(b *BlockStmt) *Completion {
 	r.beginScope()
 	r.resolveStmts(b.stmts...)
 	r.endScope()
+	return nil
 }
 
-func (r *Resolver) visitVarStmt(v *VarStmt) {
+func (r *Resolver) visitVarStmt(v *VarStmt) *Completion {
 	r.declare(v.name)
 	if v.initializer != nil {
 		r.resolveExprs(v.initializer)
 	}
 	r.define(v.name)
+	if v.constant {
+		r.constant(v.name)
+	}
+	return nil
+}
+
+// visitDestructureStmt declares every variable bound by the pattern.
+func (r *Resolver) visitDestructureStmt(d *DestructureStmt) *Completion {
+	r.resolveClasses(d.pattern)
+	names := bindings(d.pattern)
+
+	for _, name := range names {
+		r.declare(name)
+	}
+
+	r.resolveExprs(d.initializer)
+
+	for _, name := range names {
+		r.define(name)
+		if d.constant {
+			r.constant(name)
+		}
+	}
+
+	return nil
 }
 
 func (r *Resolver) visitVariable(v *Variable) any {
 	if !r.scopes.Empty() {
-		defined, declared := r.scopes.Peek()[v.name.lexeme]
+		defined, declared := r.scopes.Peek().defined[v.name.lexeme]
 
 		if declared && !defined {
-			panic(&ResolveError{"Can't read local variable in its own initializer."})
+			r.error(v.name, "Can't read local variable in its own initializer.")
 		}
 	}
 
@@ -84,62 +160,194 @@ func (r *Resolver) visitVariable(v *Variable) any {
 
 func (r *Resolver) visitAssignment(a *Assign) any {
 	r.resolveExprs(a.value)
-	r.resolveLocal(a, a.variable)
+	r.assign(a, a.variable)
 	return nil
 }
 
+func (r *Resolver) visitDestructure(d *Destructure) any {
+	r.resolveExprs(d.value)
+	for _, target := range d.targets {
+		r.assign(target, target.name)
+	}
+	return nil
+}
+
+func (r *Resolver) visitList(l *ListExpr) any {
+	r.resolveExprs(l.elements...)
+	return nil
+}
+
+// assign resolves assigned variable, const
+// globals are checked at runtime.
+func (r *Resolver) assign(expr Expr, name Token) {
+	r.resolveLocal(expr, name)
+
+	for i := r.scopes.Size() - 1; i >= 0; i-- {
+		scope := r.scopes.At(i)
+
+		if _, exists := scope.slots[name.lexeme]; exists {
+			if scope.constants[name.lexeme] {
+				r.error(name, fmt.Sprintf("Can't assign to constant '%s'.", name.lexeme))
+			}
+			break
+		}
+	}
+}
+
 func (r *Resolver) resolveLocal(expr Expr, name Token) {
 	for i := r.scopes.Size() - 1; i >= 0; i-- {
-		if _, exists := r.scopes.At(i)[name.lexeme]; exists {
-			r.interpreter.resolve(expr, r.scopes.Size()-1-i)
+		if slot, exists := r.scopes.At(i).slots[name.lexeme]; exists {
+			r.interpreter.resolve(expr, Slot{r.scopes.Size() - 1 - i, slot})
 			return
 		}
 	}
 }
 
-func (r *Resolver) visitFunStmt(fun *FunStmt) {
+func (r *Resolver) visitFunStmt(fun *FunStmt) *Completion {
 	r.declare(fun.name)
 	r.define(fun.name)
+	if fun.constant {
+		r.constant(fun.name)
+	}
 	r.resolveFun(fun)
+	return nil
 }
 
 func (r *Resolver) resolveFun(fun *FunStmt) {
+	r.resolveFunction(fun.args, fun.body, false, fun.generator)
+}
+
+func (r *Resolver) resolveFunction(args []Param, body []Stmt, initializer bool, generator bool) {
+	enclosingLoops := r.loops
+	enclosingInitializer := r.initializer
+	enclosingTails := r.tails
+	r.loops = 0
+	r.initializer = initializer
+	r.tails = !generator
+	r.functions++
+
 	r.beginScope()
-	for _, arg := range fun.args {
-		r.declare(arg)
-		r.define(arg)
+	for _, arg := range args {
+		// default value sees only parameters before it
+		if arg.value != nil {
+			r.resolveExprs(arg.value)
+		}
+
+		if arg.pattern == nil {
+			r.declare(arg.name)
+			r.define(arg.name)
+			continue
+		}
+
+		r.resolveClasses(arg.pattern)
+		for _, name := range bindings(arg.pattern) {
+			r.declare(name)
+			r.define(name)
+		}
 	}
-	r.resolveStmts(fun.body...)
+	r.resolveStmts(body...)
 	r.endScope()
+
+	r.functions--
+	r.loops = enclosingLoops
+	r.initializer = enclosingInitializer
+	r.tails = enclosingTails
 }
 
-func (r *Resolver) visitExprStmt(es *ExprStmt) {
+func (r *Resolver) visitExprStmt(es *ExprStmt) *Completion {
 	r.resolveExprs(es.expr)
+	return nil
+}
+
+func (r *Resolver) visitEnvStmt(b *EnvStmt) *Completion {
+	return nil
 }
 
-func (r *Resolver) visitBreakStmt(b *BreakStmt) {}
-func (r *Resolver) visitEnvStmt(b *EnvStmt)     {}
-func (r *Resolver) visitIfStmt(ifs *IfStmt) {
+func (r *Resolver) visitIfStmt(ifs *IfStmt) *Completion {
+	r.interpreter.coverage.instrumentBranch(ifs, ifs.name)
 	r.resolveExprs(ifs.cond)
 	r.resolveStmts(ifs.then)
 	if ifs.or != nil {
 		r.resolveStmts(ifs.or)
 	}
+	return nil
 }
 
-func (r *Resolver) visitPrintStmt(p *PrintStmt) {
+func (r *Resolver) visitPrintStmt(p *PrintStmt) *Completion {
 	r.resolveExprs(p.val)
+	return nil
 }
 
-func (r *Resolver) visitReturnStmt(ret *ReturnStmt) {
+func (r *Resolver) visitReturnStmt(ret *ReturnStmt) *Completion {
+	if r.functions == 0 {
+		r.error(ret.keyword, "Can't return from top-level code.")
+	}
+
+	if ret.value != nil && r.initializer {
+		r.error(ret.keyword, "Can't return a value from an initializer.")
+	}
+
 	if ret.value != nil {
 		r.resolveExprs(ret.value)
 	}
+
+	if _, ok := ret.value.(*Call); ok && r.tails {
+		r.interpreter.tailCallAt(ret)
+	}
+	return nil
 }
 
-func (r *Resolver) visitWhileStmt(w *WhileStmt) {
+func (r *Resolver) visitYieldStmt(y *YieldStmt) *Completion {
+	if r.functions == 0 {
+		r.error(y.keyword, "Can't yield from top-level code.")
+	}
+
+	if r.initializer {
+		r.error(y.keyword, "Can't yield from an initializer.")
+	}
+
+	if y.value != nil {
+		r.resolveExprs(y.value)
+	}
+	return nil
+}
+
+func (r *Resolver) visitWhileStmt(w *WhileStmt) *Completion {
 	r.resolveExprs(w.cond)
+	r.loops++
 	r.resolveStmts(w.body)
+	r.loops--
+	if w.incr != nil {
+		r.resolveExprs(w.incr)
+	}
+	return nil
+}
+
+func (r *Resolver) visitForInStmt(f *ForInStmt) *Completion {
+	r.resolveExprs(f.iterable)
+
+	r.beginScope()
+	r.declare(f.name)
+	r.define(f.name)
+	r.loops++
+	r.resolveStmts(f.body)
+	r.loops--
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) visitBreakStmt(b *BreakStmt) *Completion {
+	if r.loops == 0 {
+		r.error(b.keyword, "Can't break outside of a loop.")
+	}
+	return nil
+}
+
+func (r *Resolver) visitContinueStmt(c *ContinueStmt) *Completion {
+	if r.loops == 0 {
+		r.error(c.keyword, "Can't continue outside of a loop.")
+	}
+	return nil
 }
 
 func (r *Resolver) visitBinary(b *Binary) any {
@@ -156,19 +364,51 @@ func (r *Resolver) visitCall(c *Call) any {
 	return nil
 }
 
+func (r *Resolver) visitSpawn(s *Spawn) any {
+	r.resolveExprs(s.call)
+	return nil
+}
+
+// visitMatch resolves classes in patterns in the enclosing scope,
+// guard and body see variables bound by the patterns.
+func (r *Resolver) visitMatch(m *Match) any {
+	r.resolveExprs(m.value)
+
+	for _, c := range m.cases {
+		for _, pattern := range c.patterns {
+			r.resolveClasses(pattern)
+		}
+
+		r.beginScope()
+		for _, name := range c.bindings {
+			r.declare(name)
+			r.define(name)
+		}
+		if c.guard != nil {
+			r.resolveExprs(c.guard)
+		}
+		r.resolveExprs(c.body)
+		r.endScope()
+	}
+
+	return nil
+}
+
+// resolveClasses resolves classes pattern refers
+// to, before variables it binds are declared.
+func (r *Resolver) resolveClasses(pattern Pattern) {
+	for _, class := range classes(pattern) {
+		r.resolveExprs(class)
+	}
+}
+
 func (r *Resolver) visitGrouping(g *Grouping) any {
 	r.resolveExprs(g.expression)
 	return nil
 }
 
 func (r *Resolver) visitLambda(l *Lambda) any {
-	r.beginScope()
-	for _, arg := range l.args {
-		r.declare(arg)
-		r.define(arg)
-	}
-	r.resolveStmts(l.body...)
-	r.endScope()
+	r.resolveFunction(l.args, l.body, false, l.generator)
 	return nil
 }
 
@@ -177,6 +417,7 @@ func (r *Resolver) visitLiteral(l *Literal) any {
 }
 
 func (r *Resolver) visitLogical(l *Logical) any {
+	r.interpreter.coverage.instrumentBranch(l, l.operator)
 	r.resolveExprs(l.left)
 	r.resolveExprs(l.right)
 	return nil
@@ -187,9 +428,57 @@ func (r *Resolver) visitUnary(u *Unary) any {
 	return nil
 }
 
-func (r *Resolver) visitClassStmt(c *ClassStmt) {
+func (r *Resolver) visitClassStmt(c *ClassStmt) *Completion {
 	r.declare(c.name)
 	r.define(c.name)
+	if c.constant {
+		r.constant(c.name)
+	}
+
+	for _, trait := range c.traits {
+		r.resolveExprs(trait)
+	}
+
+	r.resolveMethods(c.name, c.methods)
+	return nil
+}
+
+func (r *Resolver) visitTraitStmt(t *TraitStmt) *Completion {
+	r.declare(t.name)
+	r.define(t.name)
+	r.resolveMethods(t.name, t.methods)
+	return nil
+}
+
+// resolveMethods resolves methods of class or trait. Methods are
+// bound to the instance in the environment holding only "this".
+func (r *Resolver) resolveMethods(name Token, methods []Method) {
+	r.classes++
+	r.beginScope()
+	r.declare(Token{typ: THIS, lexeme: "this", line: name.line})
+	r.define(Token{typ: THIS, lexeme: "this", line: name.line})
+
+	for _, method := range methods {
+		r.resolveFunction(method.args, method.body, isInitializer(method), method.generator)
+	}
+
+	r.endScope()
+	r.classes--
+}
+
+func (r *Resolver) visitSpread(s *Spread) any {
+	r.resolveExprs(s.list)
+	return nil
+}
+
+func (r *Resolver) visitThis(t *This) any {
+	if r.classes == 0 {
+		r.error(t.keyword, "Can't use 'this' outside of a class.")
+		return nil
+	}
+
+	r.resolveLocal(t, t.keyword)
+	return nil
 }
 
 func (r *Resolver) visitGet(g *Get) any {
@@ -1,8 +1,8 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"regexp"
 	"strconv"
 	"unicode"
@@ -18,7 +18,10 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
+	COLON
 	DOT
 	MINUS
 	PLUS
@@ -31,11 +34,15 @@ const (
 	BANG_EQUAL
 	EQUAL
 	EQUAL_EQUAL
+	ARROW
 	GREATER
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
 
+	// three chars
+	DOT_DOT_DOT
+
 	// Literals
 	IDENTIFIER
 	STRING
@@ -44,45 +51,63 @@ const (
 	// keywords
 	AND
 	BREAK
+	CASE
 	CLASS
+	CONST
+	CONTINUE
 	ENV
 	ELSE
 	FALSE
 	FUN
 	FOR
 	IF
+	IN
+	MATCH
 	NIL
 	OR
 	PRINT
 	RETURN
+	SPAWN
 	SUPER
 	THIS
+	TRAIT
 	TRUE
 	VAR
 	WHILE
+	WITH
+	YIELD
 
 	EOF
 )
 
 var keywords = map[string]TokenType{
-	"and":    AND,
-	"break":  BREAK,
-	"class":  CLASS,
-	"else":   ELSE,
-	"false":  FALSE,
-	"for":    FOR,
-	"fun":    FUN,
-	"if":     IF,
-	"nil":    NIL,
-	"or":     OR,
-	"print":  PRINT,
-	"return": RETURN,
-	"super":  SUPER,
-	"this":   THIS,
-	"true":   TRUE,
-	"var":    VAR,
-	"while":  WHILE,
-	"env":    ENV,
+	"and":      AND,
+	"break":    BREAK,
+	"case":     CASE,
+	"class":    CLASS,
+	"const":    CONST,
+	"continue": CONTINUE,
+	"else":     ELSE,
+	"false":    FALSE,
+	"for":      FOR,
+	"fun":      FUN,
+	"if":       IF,
+	"in":       IN,
+	"match":    MATCH,
+	"nil":      NIL,
+	"or":       OR,
+	"print":    PRINT,
+	"return":   RETURN,
+	"spawn":    SPAWN,
+	"super":    SUPER,
+	"this":     THIS,
+	"trait":    TRAIT,
+	"true":     TRUE,
+	"var":      VAR,
+	"while":    WHILE,
+	"with":     WITH,
+	"yield":    YIELD,
+	"env":      ENV,
 }
 
 type Token struct {
@@ -90,6 +115,7 @@ type Token struct {
 	lexeme  string
 	literal any
 	line    int
+	column  int
 }
 
 func (t *Token) String() string {
@@ -106,12 +132,14 @@ func (se *ScanError) Error() string {
 }
 
 type Scanner struct {
-	source  []byte
-	tokens  []Token
-	start   int
-	current int
-	line    int
-	err     error
+	source    []byte
+	tokens    []Token
+	start     int
+	current   int
+	line      int
+	lineStart int
+	column    int
+	err       error
 }
 
 func newScanner(source []byte) *Scanner {
@@ -122,10 +150,11 @@ func (s *Scanner) scan() ([]Token, error) {
 
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.column = s.start - s.lineStart + 1
 		s.scanToken()
 	}
 
-	s.tokens = append(s.tokens, Token{EOF, "EOF", struct{}{}, s.line})
+	s.tokens = append(s.tokens, Token{EOF, "EOF", struct{}{}, s.line, s.current - s.lineStart + 1})
 
 	return s.tokens, s.err
 }
@@ -141,10 +170,22 @@ func (s *Scanner) scanToken() {
 		s.addToken(LEFT_BRACE, struct{}{})
 	case '}':
 		s.addToken(RIGHT_BRACE, struct{}{})
+	case '[':
+		s.addToken(LEFT_BRACKET, struct{}{})
+	case ']':
+		s.addToken(RIGHT_BRACKET, struct{}{})
 	case ',':
 		s.addToken(COMMA, struct{}{})
+	case ':':
+		s.addToken(COLON, struct{}{})
 	case '.':
-		s.addToken(DOT, struct{}{})
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			s.addToken(DOT_DOT_DOT, struct{}{})
+		} else {
+			s.addToken(DOT, struct{}{})
+		}
 	case '-':
 		s.addToken(MINUS, struct{}{})
 	case '+':
@@ -163,6 +204,8 @@ func (s *Scanner) scanToken() {
 	case '=':
 		if s.match('=') {
 			s.addToken(EQUAL_EQUAL, struct{}{})
+		} else if s.match('>') {
+			s.addToken(ARROW, struct{}{})
 		} else {
 			s.addToken(EQUAL, struct{}{})
 		}
@@ -194,7 +237,7 @@ func (s *Scanner) scanToken() {
 	case '\r':
 		break
 	case '\n':
-		s.line++
+		s.newLine()
 	default:
 		if unicode.IsDigit(c) {
 			s.number()
@@ -212,7 +255,7 @@ func (s *Scanner) scanToken() {
 
 func (s *Scanner) addToken(typ TokenType, literal any) {
 	text := string(s.source[s.start:s.current])
-	s.tokens = append(s.tokens, Token{typ: typ, lexeme: text, literal: literal, line: s.line})
+	s.tokens = append(s.tokens, Token{typ: typ, lexeme: text, literal: literal, line: s.line, column: s.column})
 }
 
 func (s *Scanner) identifier() {
@@ -232,10 +275,9 @@ func (s *Scanner) identifier() {
 
 func (s *Scanner) string() {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
+		if s.advance() == '\n' {
+			s.newLine()
 		}
-		s.advance()
 	}
 
 	if s.isAtEnd() {
@@ -310,11 +352,15 @@ func (s *Scanner) match(ch rune) bool {
 	return true
 }
 
+func (s *Scanner) newLine() {
+	s.line++
+	s.lineStart = s.current
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
 func (s *Scanner) error(err *ScanError) {
-	log.Print(err)
-	s.err = err
+	s.err = errors.Join(s.err, err)
 }
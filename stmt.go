@@ -1,35 +1,52 @@
 package main
 
 type StmtVisitor interface {
-	visitIfStmt(i *IfStmt)
-	visitVarStmt(v *VarStmt)
-	visitEnvStmt(e *EnvStmt)
-	visitFunStmt(f *FunStmt)
-	visitExprStmt(es *ExprStmt)
-	visitPrintStmt(p *PrintStmt)
-	visitBlockStmt(b *BlockStmt)
-	visitWhileStmt(w *WhileStmt)
-	visitBreakStmt(b *BreakStmt)
-	visitReturnStmt(r *ReturnStmt)
-	visitClassStmt(c *ClassStmt)
+	visitIfStmt(i *IfStmt) *Completion
+	visitVarStmt(v *VarStmt) *Completion
+	visitDestructureStmt(d *DestructureStmt) *Completion
+	visitEnvStmt(e *EnvStmt) *Completion
+	visitFunStmt(f *FunStmt) *Completion
+	visitExprStmt(es *ExprStmt) *Completion
+	visitPrintStmt(p *PrintStmt) *Completion
+	visitBlockStmt(b *BlockStmt) *Completion
+	visitWhileStmt(w *WhileStmt) *Completion
+	visitForInStmt(f *ForInStmt) *Completion
+	visitBreakStmt(b *BreakStmt) *Completion
+	visitContinueStmt(c *ContinueStmt) *Completion
+	visitReturnStmt(r *ReturnStmt) *Completion
+	visitYieldStmt(y *YieldStmt) *Completion
+	visitClassStmt(c *ClassStmt) *Completion
+	visitTraitStmt(t *TraitStmt) *Completion
 }
 
 type Stmt interface {
 	stmt()
-	accept(v StmtVisitor)
+	accept(v StmtVisitor) *Completion
 }
 
 type PrintStmt struct {
-	val Expr
+	keyword Token
+	val     Expr
 }
 
 type ExprStmt struct {
 	expr Expr
 }
 
+// VarStmt declares variable, constant one can't be assigned.
 type VarStmt struct {
 	name        Token
 	initializer Expr
+	typ         *Annotation
+	constant    bool
+}
+
+// DestructureStmt declares variables bound by
+// the pattern: var [a, b] = xs; var {name} = person;
+type DestructureStmt struct {
+	pattern     Pattern
+	initializer Expr
+	constant    bool
 }
 
 type BlockStmt struct {
@@ -48,77 +65,175 @@ type IfStmt struct {
 type WhileStmt struct {
 	cond Expr
 	body Stmt
+	// desugared "for" increment, runs after
+	// each iteration even when body continues
+	incr Expr
+}
+
+// ForInStmt runs body for each value of iterable,
+// every iteration gets a new binding for name.
+type ForInStmt struct {
+	keyword  Token
+	name     Token
+	iterable Expr
+	body     Stmt
 }
 
 type ClassStmt struct {
+	name     Token
+	traits   []*Variable
+	methods  []Method
+	constant bool
+}
+
+// TraitStmt declares methods copied into classes using the trait.
+type TraitStmt struct {
 	name    Token
-	methods []FunStmt
+	methods []Method
 }
 
-type BreakStmt struct{}
+type MethodKind int
+
+const (
+	plainMethod MethodKind = iota
+	getterMethod
+	setterMethod
+)
+
+// Method is a function declared in class body. Static methods
+// are called on the class instead of instances, method replacing
+// one that comes from a trait has to be marked as override.
+type Method struct {
+	FunStmt
+	kind     MethodKind
+	static   bool
+	override bool
+}
+
+type BreakStmt struct {
+	keyword Token
+}
+
+type ContinueStmt struct {
+	keyword Token
+}
 
 type FunStmt struct {
 	name Token
-	args []Token
+	args []Param
 	body []Stmt
+	// body has "yield"
+	generator bool
+	returns   *Annotation
+	// declared with "const fun"
+	constant bool
+}
+
+// Param is a function parameter. Parameter with value is optional
+// and gets the value when argument is missing, rest parameter
+// collects all remaining arguments into a list. Parameter with
+// pattern declares variables bound by it instead of name.
+type Param struct {
+	name    Token
+	value   Expr
+	rest    bool
+	typ     *Annotation
+	pattern Pattern
+}
+
+// Annotation is an optional type written after ":". Annotations
+// are checked by Checker and by Interpreter in strict mode only.
+type Annotation struct {
+	name Token
 }
 
 type ReturnStmt struct {
-	value Expr
+	keyword Token
+	value   Expr
+}
+
+type YieldStmt struct {
+	keyword Token
+	value   Expr
+}
+
+func (i *IfStmt) stmt()          {}
+func (f *FunStmt) stmt()         {}
+func (e *EnvStmt) stmt()         {}
+func (vs *VarStmt) stmt()        {}
+func (d *DestructureStmt) stmt() {}
+func (es *ExprStmt) stmt()       {}
+func (p *PrintStmt) stmt()       {}
+func (b *BlockStmt) stmt()       {}
+func (w *WhileStmt) stmt()       {}
+func (f *ForInStmt) stmt()       {}
+func (b *BreakStmt) stmt()       {}
+func (c *ContinueStmt) stmt()    {}
+func (r *ReturnStmt) stmt()      {}
+func (y *YieldStmt) stmt()       {}
+func (c *ClassStmt) stmt()       {}
+func (t *TraitStmt) stmt()       {}
+
+func (p *PrintStmt) accept(v StmtVisitor) *Completion {
+	return v.visitPrintStmt(p)
 }
 
-func (i *IfStmt) stmt()     {}
-func (f *FunStmt) stmt()    {}
-func (e *EnvStmt) stmt()    {}
-func (vs *VarStmt) stmt()   {}
-func (es *ExprStmt) stmt()  {}
-func (p *PrintStmt) stmt()  {}
-func (b *BlockStmt) stmt()  {}
-func (w *WhileStmt) stmt()  {}
-func (b *BreakStmt) stmt()  {}
-func (r *ReturnStmt) stmt() {}
-func (c *ClassStmt) stmt()  {}
+func (se *ExprStmt) accept(v StmtVisitor) *Completion {
+	return v.visitExprStmt(se)
+}
+
+func (vs *VarStmt) accept(v StmtVisitor) *Completion {
+	return v.visitVarStmt(vs)
+}
+
+func (d *DestructureStmt) accept(v StmtVisitor) *Completion {
+	return v.visitDestructureStmt(d)
+}
+
+func (b *BlockStmt) accept(v StmtVisitor) *Completion {
+	return v.visitBlockStmt(b)
+}
 
-func (p *PrintStmt) accept(v StmtVisitor) {
-	v.visitPrintStmt(p)
+func (i *IfStmt) accept(v StmtVisitor) *Completion {
+	return v.visitIfStmt(i)
 }
 
-func (se *ExprStmt) accept(v StmtVisitor) {
-	v.visitExprStmt(se)
+func (e *EnvStmt) accept(v StmtVisitor) *Completion {
+	return v.visitEnvStmt(e)
 }
 
-func (vs *VarStmt) accept(v StmtVisitor) {
-	v.visitVarStmt(vs)
+func (w *WhileStmt) accept(v StmtVisitor) *Completion {
+	return v.visitWhileStmt(w)
 }
 
-func (b *BlockStmt) accept(v StmtVisitor) {
-	v.visitBlockStmt(b)
+func (f *ForInStmt) accept(v StmtVisitor) *Completion {
+	return v.visitForInStmt(f)
 }
 
-func (i *IfStmt) accept(v StmtVisitor) {
-	v.visitIfStmt(i)
+func (b *BreakStmt) accept(v StmtVisitor) *Completion {
+	return v.visitBreakStmt(b)
 }
 
-func (e *EnvStmt) accept(v StmtVisitor) {
-	v.visitEnvStmt(e)
+func (c *ContinueStmt) accept(v StmtVisitor) *Completion {
+	return v.visitContinueStmt(c)
 }
 
-func (w *WhileStmt) accept(v StmtVisitor) {
-	v.visitWhileStmt(w)
+func (f *FunStmt) accept(v StmtVisitor) *Completion {
+	return v.visitFunStmt(f)
 }
 
-func (b *BreakStmt) accept(v StmtVisitor) {
-	v.visitBreakStmt(b)
+func (r *ReturnStmt) accept(v StmtVisitor) *Completion {
+	return v.visitReturnStmt(r)
 }
 
-func (f *FunStmt) accept(v StmtVisitor) {
-	v.visitFunStmt(f)
+func (c *ClassStmt) accept(v StmtVisitor) *Completion {
+	return v.visitClassStmt(c)
 }
 
-func (r *ReturnStmt) accept(v StmtVisitor) {
-	v.visitReturnStmt(r)
+func (t *TraitStmt) accept(v StmtVisitor) *Completion {
+	return v.visitTraitStmt(t)
 }
 
-func (c *ClassStmt) accept(v StmtVisitor) {
-	v.visitClassStmt(c)
+func (y *YieldStmt) accept(v StmtVisitor) *Completion {
+	return v.visitYieldStmt(y)
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// stringify converts value to string the way "print" shows it.
+// Instances are converted with their "toString()" method if
+// class defines one, lists and maps convert elements the same way.
+func (i *Interpreter) stringify(val any) string {
+	return (&formatter{interpreter: i}).format(val)
+}
+
+// stringify converts value to string without calling user code.
+func stringify(val any) string {
+	return (&formatter{}).format(val)
+}
+
+// formatter converts values to strings, lists and maps
+// containing themselves are shown as [...] and {...}.
+type formatter struct {
+	// calls "toString()" methods when set
+	interpreter *Interpreter
+	// lists and maps being formatted
+	visiting map[any]bool
+}
+
+func (f *formatter) format(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return formatNumber(v)
+	case string:
+		return v
+	case *ClassInstance:
+		if method, ok := v.klass.methods["toString"]; ok && f.interpreter != nil && accepts(method, 0) {
+			// don't call "toString" on the result again,
+			// it may return the instance itself
+			return stringify(method.bind(v).call(f.interpreter))
+		}
+	case *List:
+		return f.container(v, "[...]", func() string { return v.format(f.format) })
+	case *Map:
+		return f.container(v, "{...}", func() string { return v.format(f.format) })
+	}
+
+	if stringer, ok := val.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	return fmt.Sprint(val)
+}
+
+func (f *formatter) container(val any, cycle string, format func() string) string {
+	if f.visiting[val] {
+		return cycle
+	}
+
+	if f.visiting == nil {
+		f.visiting = map[any]bool{}
+	}
+
+	f.visiting[val] = true
+	defer delete(f.visiting, val)
+
+	return format()
+}
+
+// formatNumber prints integers without fraction and other numbers
+// with the shortest representation that reads back the same value.
+// Exponent is used only for very big and very small numbers.
+func formatNumber(num float64) string {
+	switch {
+	case math.IsNaN(num):
+		return "nan"
+	case math.IsInf(num, 1):
+		return "inf"
+	case math.IsInf(num, -1):
+		return "-inf"
+	case num != 0 && (math.Abs(num) >= 1e21 || math.Abs(num) < 1e-6):
+		return strconv.FormatFloat(num, 'g', -1, 64)
+	}
+
+	return strconv.FormatFloat(num, 'f', -1, 64)
+}
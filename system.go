@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// FileSystem is what natives use to touch files. Reading goes through
+// the standard fs.FS so any fs.FS implementation (os.DirFS, fstest.MapFS)
+// can be plugged in, writing is an extension on top of it.
+type FileSystem interface {
+	fs.FS
+	WriteFile(name string, data []byte) error
+	AppendFile(name string, data []byte) error
+}
+
+// System is everything outside of the interpreter
+// scripts are allowed to talk to.
+type System struct {
+	fs     FileSystem
+	stdin  *bufio.Reader
+	stdout io.Writer
+	args   []string
+	getenv func(string) string
+	exit   func(int)
+	// tasks read lines concurrently
+	stdinMu sync.Mutex
+	caps    Capabilities
+}
+
+func newSystem(fs FileSystem, stdin io.Reader, stdout io.Writer, args []string) *System {
+	return &System{
+		fs:     fs,
+		stdin:  bufio.NewReader(stdin),
+		stdout: &syncWriter{w: stdout},
+		args:   args,
+		getenv: func(string) string { return "" },
+		exit:   func(int) {},
+		caps:   allCapabilities(),
+	}
+}
+
+func newOsSystem(args []string, caps Capabilities) *System {
+	sys := newSystem(osFS{}, os.Stdin, os.Stdout, args)
+	sys.caps = caps
+	sys.getenv = os.Getenv
+	sys.exit = os.Exit
+	return sys
+}
+
+// osFS passes paths to the os package as is, so scripts
+// can use both relative and absolute paths.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFS) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0644)
+}
+
+func (osFS) AppendFile(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+
+	return errors.Join(err, file.Close())
+}
+
+// syncWriter serializes writes from concurrent tasks,
+// so printed lines are never interleaved.
+type syncWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return sw.w.Write(p)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type mapFS struct {
+	fstest.MapFS
+}
+
+func (m mapFS) WriteFile(name string, data []byte) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data}
+	return nil
+}
+
+func (m mapFS) AppendFile(name string, data []byte) error {
+	if file, ok := m.MapFS[name]; ok {
+		data = append(file.Data, data...)
+	}
+	return m.WriteFile(name, data)
+}
+
+func TestStubbedSystem(t *testing.T) {
+	fsys := mapFS{fstest.MapFS{"in.txt": {Data: []byte("from file")}}}
+	stdout := &strings.Builder{}
+	sys := newSystem(fsys, strings.NewReader("first\nsecond"), stdout, []string{"arg"})
+
+	code := -1
+	sys.exit = func(c int) { code = c }
+
+	source := `
+		writeFile("out.txt", readFile("in.txt"));
+		appendFile("out.txt", " and " + readLine() + " " + readLine());
+		print readFile("out.txt");
+		print readLine();
+		print exists("out.txt");
+		print args.get(0);
+		exit(3);
+	`
+
+	runScript(t, newInterpreter(sys), source)
+
+	expected := "from file and first second\nnil\ntrue\narg\n"
+
+	if stdout.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, stdout.String())
+	}
+
+	if code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+}
+
+func TestSandboxedSystem(t *testing.T) {
+	fsys := mapFS{fstest.MapFS{
+		"data/in.txt":   {Data: []byte("allowed")},
+		"secret/in.txt": {Data: []byte("denied")},
+	}}
+	stdout := &strings.Builder{}
+	sys := newSystem(fsys, strings.NewReader(""), stdout, nil)
+	sys.caps = noCapabilities()
+	sys.caps.read = true
+	sys.caps.readDirs = []string{"data"}
+
+	tests := map[string]string{
+		`print readFile("data/in.txt");`:           "",
+		`print readFile("data/../secret/in.txt");`: "readFile: read data/../secret/in.txt: permission denied",
+		`print exists("secret/in.txt");`:           "exists: read secret/in.txt: permission denied",
+		`writeFile("data/out.txt", "");`:           "writeFile: write data/out.txt: permission denied",
+		`getenv("HOME");`:                          "getenv: environment access is not allowed",
+		`clock();`:                                 "clock: clock access is not allowed",
+		`readLine();`:                              "readLine: reading stdin is not allowed",
+		`exit(1);`:                                 "exit: exit is not allowed",
+	}
+
+	for source, expected := range tests {
+		interpreter := newInterpreter(sys)
+		err := interpreter.interpret(compileScript(t, interpreter, source))
+
+		if expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", source, err)
+		}
+
+		if expected != "" && (err == nil || !strings.Contains(err.Error(), expected)) {
+			t.Errorf("%s: expected error %q, got %v", source, expected, err)
+		}
+	}
+
+	if stdout.String() != "allowed\n" {
+		t.Fatalf("expected allowed file content, got %q", stdout.String())
+	}
+}
+
+func TestSandboxFollowsSymlinks(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	secret := filepath.Join(root, "secret")
+
+	for _, dir := range []string{allowed, secret} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		filepath.Join(allowed, "in.txt"): "allowed",
+		filepath.Join(secret, "in.txt"):  "denied",
+	}
+
+	links := map[string]string{
+		// file, directory and not yet existing file outside
+		filepath.Join(allowed, "file"): filepath.Join(secret, "in.txt"),
+		filepath.Join(allowed, "dir"):  "../secret",
+		filepath.Join(allowed, "new"):  filepath.Join(secret, "new.txt"),
+		// link inside of allowed directory is fine
+		filepath.Join(allowed, "same"): "in.txt",
+	}
+
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skip("symlinks are not supported: ", err)
+		}
+	}
+
+	stdout := &strings.Builder{}
+	sys := newSystem(osFS{}, strings.NewReader(""), stdout, nil)
+	sys.caps = noCapabilities()
+	sys.caps.read = true
+	sys.caps.readDirs = []string{allowed}
+	sys.caps.write = true
+	sys.caps.writeDirs = []string{allowed}
+
+	tests := map[string]string{
+		`print readFile("` + allowed + `/same");`:              "",
+		`print readFile("` + allowed + `/file");`:              "permission denied",
+		`print readFile("` + allowed + `/dir/in.txt");`:        "permission denied",
+		`writeFile("` + allowed + `/dir/out.txt", "escaped");`: "permission denied",
+		`writeFile("` + allowed + `/new", "escaped");`:         "permission denied",
+	}
+
+	for source, expected := range tests {
+		interpreter := newInterpreter(sys)
+		err := interpreter.interpret(compileScript(t, interpreter, source))
+
+		if expected == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", source, err)
+		}
+
+		if expected != "" && (err == nil || !strings.Contains(err.Error(), expected)) {
+			t.Errorf("%s: expected error %q, got %v", source, expected, err)
+		}
+	}
+
+	if stdout.String() != "allowed\n" {
+		t.Fatalf("expected allowed file content, got %q", stdout.String())
+	}
+
+	for _, escaped := range []string{filepath.Join(secret, "out.txt"), filepath.Join(secret, "new.txt")} {
+		if _, err := os.Stat(escaped); err == nil {
+			t.Errorf("%s was written outside of the sandbox", escaped)
+		}
+	}
+}
+
+func TestSandboxIgnoresHostForOtherFS(t *testing.T) {
+	root := t.TempDir()
+
+	// host file with the same path as the in-memory one links outside
+	if err := os.Mkdir(filepath.Join(root, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("../secret", filepath.Join(root, "data", "in.txt")); err != nil {
+		t.Skip("symlinks are not supported: ", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	fsys := mapFS{fstest.MapFS{
+		"data/in.txt":   {Data: []byte("allowed")},
+		"secret/in.txt": {Data: []byte("denied")},
+	}}
+	stdout := &strings.Builder{}
+	sys := newSystem(fsys, strings.NewReader(""), stdout, nil)
+	sys.caps = noCapabilities()
+	sys.caps.read = true
+	sys.caps.readDirs = []string{"data"}
+	interpreter := newInterpreter(sys)
+
+	runScript(t, interpreter, `print readFile("data/in.txt");`)
+
+	err = interpreter.interpret(compileScript(t, interpreter, `print readFile("data/../secret/in.txt");`))
+
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+
+	if stdout.String() != "allowed\n" {
+		t.Fatalf("expected allowed file content, got %q", stdout.String())
+	}
+}
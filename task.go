@@ -0,0 +1,394 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// scheduler counts goroutines running Lox code, the main one and
+// tasks, and how many of them wait for channels or other tasks.
+// When all of them wait nobody can wake them up, so waiters get
+// an error instead of Go runtime killing the whole process.
+//
+// Generator body runs only while its caller waits for it, so
+// they are counted as one goroutine. All channel and task state
+// is guarded by the scheduler lock.
+type scheduler struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	live    int
+	waiting int
+	// incremented every time all goroutines wait
+	deadlocks int
+}
+
+var errDeadlock = errors.New("deadlock, all tasks are waiting for channels or tasks")
+
+func newScheduler() *scheduler {
+	s := &scheduler{live: 1}
+	s.changed = sync.NewCond(&s.mu)
+	return s
+}
+
+// wait blocks until ready returns true, caller holds the lock.
+func (s *scheduler) wait(ready func() bool) error {
+	deadlocks := s.deadlocks
+
+	for !ready() {
+		s.waiting++
+		s.detect()
+
+		if s.deadlocks == deadlocks {
+			s.changed.Wait()
+		}
+
+		if s.deadlocks != deadlocks {
+			return errDeadlock
+		}
+	}
+
+	return nil
+}
+
+// wake lets waiters check if they can go on, until they
+// wait again they are counted as running. Caller holds the lock.
+func (s *scheduler) wake() {
+	s.waiting = 0
+	s.changed.Broadcast()
+}
+
+// detect wakes everybody up with error when nobody runs,
+// caller holds the lock.
+func (s *scheduler) detect() {
+	if s.live > 0 && s.waiting == s.live {
+		s.deadlocks++
+		s.wake()
+	}
+}
+
+// Task is a handle of a call started with "spawn". The call runs on its
+// own goroutine with forked interpreter, errors are kept until somebody
+// waits for the task. Program doesn't wait for tasks when the script
+// ends, so scripts should wait for tasks they care about.
+type Task struct {
+	scheduler *scheduler
+	finished  bool
+	result    any
+	// runtime error or internal panic of the call
+	err any
+}
+
+func newTask(s *scheduler) *Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// counted before the goroutine starts, so
+	// nobody sees everybody waiting in between
+	s.live++
+
+	return &Task{scheduler: s}
+}
+
+func (t *Task) run(call func() (any, *RuntimeError)) {
+	var thrown *RuntimeError
+
+	defer func() {
+		// internal panic of the call
+		err := recover()
+
+		if err == nil && thrown != nil {
+			err = thrown
+		}
+
+		s := t.scheduler
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		t.err = err
+		t.finished = true
+		s.live--
+		s.wake()
+		s.detect()
+	}()
+
+	t.result, thrown = call()
+}
+
+// wait blocks until task is finished and returns its
+// result or rethrows its error in the waiting interpreter.
+func (t *Task) wait(i *Interpreter) (any, error) {
+	s := t.scheduler
+	s.mu.Lock()
+	err := s.wait(func() bool { return t.finished })
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if re, ok := t.err.(*RuntimeError); ok {
+		i.throw(re)
+		return nil, nil
+	}
+
+	if t.err != nil {
+		panic(t.err)
+	}
+
+	return t.result, nil
+}
+
+func (t *Task) done() bool {
+	t.scheduler.mu.Lock()
+	defer t.scheduler.mu.Unlock()
+
+	return t.finished
+}
+
+func (t *Task) get(name string) (any, bool) {
+	switch name {
+	case "wait":
+		return newNative("wait", 0, func(i *Interpreter, args ...any) (any, error) {
+			return t.wait(i)
+		}), true
+	case "done":
+		return newNative("done", 0, func(i *Interpreter, args ...any) (any, error) {
+			return t.done(), nil
+		}), true
+	}
+
+	return nil, false
+}
+
+func (t *Task) String() string {
+	return "<task>"
+}
+
+// Channel passes values between tasks, receiving
+// from closed and drained channel gives nil.
+type Channel struct {
+	scheduler *scheduler
+	capacity  int
+	buffer    []any
+	// unbuffered values and values not fitting
+	// into the buffer wait here for receivers
+	senders []*pendingSend
+	closed  bool
+}
+
+type pendingSend struct {
+	value any
+	taken bool
+}
+
+var errSendOnClosed = errors.New("send on closed channel")
+
+// channel() or channel(capacity)
+func makeChannel(i *Interpreter, args ...any) (any, error) {
+	capacity := 0.0
+
+	if len(args) > 0 {
+		num, ok := args[0].(float64)
+
+		if !ok || num < 0 || num != float64(int(num)) {
+			return nil, fmt.Errorf("capacity must be a non negative integer, got %s", stringify(args[0]))
+		}
+
+		capacity = num
+	}
+
+	return &Channel{scheduler: i.scheduler, capacity: int(capacity)}, nil
+}
+
+func (c *Channel) send(val any) error {
+	s := c.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.closed {
+		return errSendOnClosed
+	}
+
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, val)
+		s.wake()
+		return nil
+	}
+
+	pending := &pendingSend{value: val}
+	c.senders = append(c.senders, pending)
+	s.wake()
+
+	err := s.wait(func() bool { return pending.taken || c.closed })
+
+	if pending.taken {
+		return nil
+	}
+
+	c.senders = remove(c.senders, pending)
+
+	if err != nil {
+		return err
+	}
+
+	return errSendOnClosed
+}
+
+func remove(senders []*pendingSend, pending *pendingSend) []*pendingSend {
+	kept := []*pendingSend{}
+	for _, sender := range senders {
+		if sender != pending {
+			kept = append(kept, sender)
+		}
+	}
+	return kept
+}
+
+// take receives value if channel has one or is closed, ok is
+// false for closed channel and ready is false when receiver
+// has to wait. Caller holds the scheduler lock.
+func (c *Channel) take() (value any, ok bool, ready bool) {
+	if len(c.buffer) > 0 {
+		value, c.buffer = c.buffer[0], c.buffer[1:]
+
+		// buffer has room for the first waiting sender now
+		if len(c.senders) > 0 {
+			c.buffer = append(c.buffer, c.senders[0].value)
+			c.senders[0].taken = true
+			c.senders = c.senders[1:]
+		}
+
+		c.scheduler.wake()
+		return value, true, true
+	}
+
+	if len(c.senders) > 0 {
+		value = c.senders[0].value
+		c.senders[0].taken = true
+		c.senders = c.senders[1:]
+
+		c.scheduler.wake()
+		return value, true, true
+	}
+
+	return nil, false, c.closed
+}
+
+// recv waits for a value, ok is false when channel is closed and drained.
+func (c *Channel) recv() (value any, ok bool, err error) {
+	s := c.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.wait(func() bool {
+		var ready bool
+		value, ok, ready = c.take()
+		return ready
+	})
+
+	return value, ok, err
+}
+
+func (c *Channel) close() error {
+	s := c.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.closed {
+		return errors.New("channel is already closed")
+	}
+
+	c.closed = true
+	s.wake()
+
+	return nil
+}
+
+func (c *Channel) get(name string) (any, bool) {
+	switch name {
+	case "send":
+		return newNative("send", 1, func(i *Interpreter, args ...any) (any, error) {
+			return nil, c.send(args[0])
+		}), true
+	case "recv":
+		return newNative("recv", 0, func(i *Interpreter, args ...any) (any, error) {
+			value, _, err := c.recv()
+			return value, err
+		}), true
+	case "close":
+		return newNative("close", 0, func(i *Interpreter, args ...any) (any, error) {
+			return nil, c.close()
+		}), true
+	}
+
+	return nil, false
+}
+
+func (c *Channel) String() string {
+	return "<channel>"
+}
+
+// select(...channels) waits until one of the channels has a value
+// and returns list of that channel and the value. Closed channel
+// is always ready with nil value. When several channels are
+// ready the first one of them is chosen.
+func selectChannel(i *Interpreter, args ...any) (any, error) {
+	channels := []*Channel{}
+
+	for _, arg := range args {
+		channel, ok := arg.(*Channel)
+
+		if !ok {
+			return nil, fmt.Errorf("expected channels, got %s", stringify(arg))
+		}
+
+		channels = append(channels, channel)
+	}
+
+	s := i.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chosen, value any
+
+	err := s.wait(func() bool {
+		for idx, channel := range channels {
+			if val, _, ready := channel.take(); ready {
+				chosen, value = args[idx], val
+				return true
+			}
+		}
+		return false
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newList(chosen, value), nil
+}
+
+// channelIterator receives values until channel is closed.
+type channelIterator struct {
+	interpreter *Interpreter
+	token       Token
+	channel     *Channel
+	value       any
+}
+
+func (ci *channelIterator) done() bool {
+	val, ok, err := ci.channel.recv()
+
+	if err != nil {
+		ci.interpreter.throw(&RuntimeError{ci.token, err.Error()})
+		return true
+	}
+
+	ci.value = val
+	return !ok
+}
+
+func (ci *channelIterator) next() any {
+	return ci.value
+}
@@ -12,48 +12,62 @@ func _() {
 	_ = x[RIGHT_PAREN-1]
 	_ = x[LEFT_BRACE-2]
 	_ = x[RIGHT_BRACE-3]
-	_ = x[COMMA-4]
-	_ = x[DOT-5]
-	_ = x[MINUS-6]
-	_ = x[PLUS-7]
-	_ = x[SEMICOLON-8]
-	_ = x[SLASH-9]
-	_ = x[STAR-10]
-	_ = x[BANG-11]
-	_ = x[BANG_EQUAL-12]
-	_ = x[EQUAL-13]
-	_ = x[EQUAL_EQUAL-14]
-	_ = x[GREATER-15]
-	_ = x[GREATER_EQUAL-16]
-	_ = x[LESS-17]
-	_ = x[LESS_EQUAL-18]
-	_ = x[IDENTIFIER-19]
-	_ = x[STRING-20]
-	_ = x[NUMBER-21]
-	_ = x[AND-22]
-	_ = x[BREAK-23]
-	_ = x[CLASS-24]
-	_ = x[ENV-25]
-	_ = x[ELSE-26]
-	_ = x[FALSE-27]
-	_ = x[FUN-28]
-	_ = x[FOR-29]
-	_ = x[IF-30]
-	_ = x[NIL-31]
-	_ = x[OR-32]
-	_ = x[PRINT-33]
-	_ = x[RETURN-34]
-	_ = x[SUPER-35]
-	_ = x[THIS-36]
-	_ = x[TRUE-37]
-	_ = x[VAR-38]
-	_ = x[WHILE-39]
-	_ = x[EOF-40]
+	_ = x[LEFT_BRACKET-4]
+	_ = x[RIGHT_BRACKET-5]
+	_ = x[COMMA-6]
+	_ = x[COLON-7]
+	_ = x[DOT-8]
+	_ = x[MINUS-9]
+	_ = x[PLUS-10]
+	_ = x[SEMICOLON-11]
+	_ = x[SLASH-12]
+	_ = x[STAR-13]
+	_ = x[BANG-14]
+	_ = x[BANG_EQUAL-15]
+	_ = x[EQUAL-16]
+	_ = x[EQUAL_EQUAL-17]
+	_ = x[ARROW-18]
+	_ = x[GREATER-19]
+	_ = x[GREATER_EQUAL-20]
+	_ = x[LESS-21]
+	_ = x[LESS_EQUAL-22]
+	_ = x[DOT_DOT_DOT-23]
+	_ = x[IDENTIFIER-24]
+	_ = x[STRING-25]
+	_ = x[NUMBER-26]
+	_ = x[AND-27]
+	_ = x[BREAK-28]
+	_ = x[CASE-29]
+	_ = x[CLASS-30]
+	_ = x[CONST-31]
+	_ = x[CONTINUE-32]
+	_ = x[ENV-33]
+	_ = x[ELSE-34]
+	_ = x[FALSE-35]
+	_ = x[FUN-36]
+	_ = x[FOR-37]
+	_ = x[IF-38]
+	_ = x[IN-39]
+	_ = x[MATCH-40]
+	_ = x[NIL-41]
+	_ = x[OR-42]
+	_ = x[PRINT-43]
+	_ = x[RETURN-44]
+	_ = x[SPAWN-45]
+	_ = x[SUPER-46]
+	_ = x[THIS-47]
+	_ = x[TRAIT-48]
+	_ = x[TRUE-49]
+	_ = x[VAR-50]
+	_ = x[WHILE-51]
+	_ = x[WITH-52]
+	_ = x[YIELD-53]
+	_ = x[EOF-54]
 }
 
-const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERANDBREAKCLASSENVELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEEOF"
+const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACELEFT_BRACKETRIGHT_BRACKETCOMMACOLONDOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALARROWGREATERGREATER_EQUALLESSLESS_EQUALDOT_DOT_DOTIDENTIFIERSTRINGNUMBERANDBREAKCASECLASSCONSTCONTINUEENVELSEFALSEFUNFORIFINMATCHNILORPRINTRETURNSPAWNSUPERTHISTRAITTRUEVARWHILEWITHYIELDEOF"
 
-var _TokenType_index = [...]uint8{0, 10, 21, 31, 42, 47, 50, 55, 59, 68, 73, 77, 81, 91, 96, 107, 114, 127, 131, 141, 151, 157, 163, 166, 171, 176, 179, 183, 188, 191, 194, 196, 199, 201, 206, 212, 217, 221, 225, 228, 233, 236}
+var _TokenType_index = [...]uint16{0, 10, 21, 31, 42, 54, 67, 72, 77, 80, 85, 89, 98, 103, 107, 111, 121, 126, 137, 142, 149, 162, 166, 176, 187, 197, 203, 209, 212, 217, 221, 226, 231, 239, 242, 246, 251, 254, 257, 259, 261, 266, 269, 271, 276, 282, 287, 292, 296, 301, 305, 308, 313, 317, 322, 325}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
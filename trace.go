@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// Trace writes what interpreter does as JSON lines: statements,
+// calls with their arguments and return values, variable
+// declarations and assignments, each with its line and call depth.
+//
+//	{"depth":0,"event":"stmt","line":5,"stmt":"PrintStmt"}
+//	{"args":[2],"depth":1,"event":"call","function":"double","line":5}
+//	{"depth":1,"event":"return","function":"double","line":2,"value":4}
+//
+// Tasks and generators write to the same trace.
+type Trace struct {
+	out *bufio.Writer
+	// trace only calls of the function with this name
+	// and everything they do, empty traces everything
+	function string
+	// stop tracing after writing this many bytes, 0 is no limit
+	limit     int64
+	written   int64
+	truncated bool
+	mu        sync.Mutex
+}
+
+// tracer is the position of one interpreter in the Trace.
+type tracer struct {
+	trace *Trace
+	depth int
+	line  int
+	// calls of the traced function on the stack
+	inside int
+	// lines of the callers to get back to after return
+	callers []int
+}
+
+func newTrace(w io.Writer, function string, limit int64) *Trace {
+	return &Trace{out: bufio.NewWriter(w), function: function, limit: limit}
+}
+
+func newTracer(trace *Trace) *tracer {
+	return &tracer{trace: trace, callers: []int{}}
+}
+
+// fork starts tracing another interpreter at the current position.
+func (t *tracer) fork() *tracer {
+	if t == nil {
+		return nil
+	}
+
+	return &tracer{t.trace, t.depth, t.line, t.inside, []int{}}
+}
+
+func (t *tracer) statement(stmt Stmt) {
+	// statements inside of blocks are traced on their own
+	if _, ok := stmt.(*BlockStmt); ok {
+		return
+	}
+
+	if line := stmtLine(stmt); line != 0 {
+		t.line = line
+	}
+
+	t.write(map[string]any{"event": "stmt", "stmt": reflect.TypeOf(stmt).Elem().Name()})
+}
+
+func (t *tracer) call(f *Function, args []any) {
+	if f.label() == t.trace.function {
+		t.inside++
+	}
+
+	values := []any{}
+	for _, arg := range args {
+		values = append(values, traceValue(arg))
+	}
+
+	t.depth++
+	t.write(map[string]any{"event": "call", "function": f.label(), "args": values})
+
+	t.callers = append(t.callers, t.line)
+	t.line = f.name.line
+}
+
+// ret traces value returned by f, or error thrown out of it.
+func (t *tracer) ret(f *Function, value any, err any) {
+	event := map[string]any{"event": "return", "function": f.label()}
+
+	switch {
+	case err != nil:
+		event["error"] = traceError(err)
+	case isTailCall(value):
+		event["tail"] = true
+	default:
+		event["value"] = traceValue(value)
+	}
+
+	t.write(event)
+	t.depth--
+
+	if n := len(t.callers); n > 0 {
+		t.line = t.callers[n-1]
+		t.callers = t.callers[:n-1]
+	}
+
+	if f.label() == t.trace.function {
+		t.inside--
+	}
+}
+
+func isTailCall(value any) bool {
+	_, ok := value.(*TailCall)
+	return ok
+}
+
+// assign traces variable being declared or assigned.
+func (t *tracer) assign(event string, name Token, value any) {
+	t.line = name.line
+	t.write(map[string]any{"event": event, "name": name.lexeme, "value": traceValue(value)})
+}
+
+func (t *tracer) write(event map[string]any) {
+	if t.trace.function != "" && t.inside == 0 {
+		return
+	}
+
+	event["line"] = t.line
+	event["depth"] = t.depth
+
+	t.trace.write(event)
+}
+
+func (tr *Trace) write(event map[string]any) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.truncated {
+		return
+	}
+
+	line := encodeEvent(event)
+
+	if tr.limit > 0 && tr.written+int64(len(line)) > tr.limit {
+		tr.truncated = true
+		line = encodeEvent(map[string]any{"event": "truncated", "limit": tr.limit})
+	}
+
+	tr.out.Write(line)
+	tr.written += int64(len(line))
+}
+
+// encodeEvent returns event as a line of JSON, values are
+// printed like "<fn name>" so HTML characters aren't escaped.
+func encodeEvent(event map[string]any) []byte {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(event)
+
+	return buf.Bytes()
+}
+
+func (tr *Trace) flush() error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	return tr.out.Flush()
+}
+
+func traceError(err any) string {
+	if re, ok := err.(*RuntimeError); ok {
+		return re.msg
+	}
+
+	return fmt.Sprint(err)
+}
+
+// traceValue keeps values JSON has as they are and writes the
+// rest with stringify. Unlike "print" it doesn't call "toString()",
+// so tracing never runs user code.
+func traceValue(value any) any {
+	switch v := value.(type) {
+	case nil, bool, string:
+		return v
+	case float64:
+		if !math.IsInf(v, 0) && !math.IsNaN(v) {
+			return v
+		}
+	}
+
+	return stringify(value)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// traceScript returns trace of the script and its runtime error.
+func traceScript(t *testing.T, source string, function string, limit int64) (string, error) {
+	out := &strings.Builder{}
+	trace := newTrace(out, function, limit)
+	interpreter := testInterpreter(io.Discard)
+	interpreter.tracer = newTracer(trace)
+
+	err := interpreter.interpret(compileScript(t, interpreter, source))
+
+	if err := trace.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.String(), err
+}
+
+func TestTrace(t *testing.T) {
+	source := `fun double(x) {
+  return x * 2;
+}
+var a = 1;
+a = double(a) + double(2);
+fun fail() { return nil + 1; }
+fail();
+`
+
+	expected := `{"depth":0,"event":"stmt","line":1,"stmt":"FunStmt"}
+{"depth":0,"event":"define","line":1,"name":"double","value":"<fn double>"}
+{"depth":0,"event":"stmt","line":4,"stmt":"VarStmt"}
+{"depth":0,"event":"define","line":4,"name":"a","value":1}
+{"depth":0,"event":"stmt","line":5,"stmt":"ExprStmt"}
+{"args":[1],"depth":1,"event":"call","function":"double","line":5}
+{"depth":1,"event":"stmt","line":2,"stmt":"ReturnStmt"}
+{"depth":1,"event":"return","function":"double","line":2,"value":2}
+{"args":[2],"depth":1,"event":"call","function":"double","line":5}
+{"depth":1,"event":"stmt","line":2,"stmt":"ReturnStmt"}
+{"depth":1,"event":"return","function":"double","line":2,"value":4}
+{"depth":0,"event":"assign","line":5,"name":"a","value":6}
+{"depth":0,"event":"stmt","line":6,"stmt":"FunStmt"}
+{"depth":0,"event":"define","line":6,"name":"fail","value":"<fn fail>"}
+{"depth":0,"event":"stmt","line":7,"stmt":"ExprStmt"}
+{"args":[],"depth":1,"event":"call","function":"fail","line":7}
+{"depth":1,"event":"stmt","line":6,"stmt":"ReturnStmt"}
+{"depth":1,"error":"Operands must be numbers or strings: nil + 1","event":"return","function":"fail","line":6}
+`
+
+	trace, err := traceScript(t, source, "", 0)
+
+	if trace != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, trace)
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "Operands must be numbers or strings: nil + 1") {
+		t.Errorf("expected runtime error, got %v", err)
+	}
+
+	filtered := `{"args":[1],"depth":1,"event":"call","function":"double","line":5}
+{"depth":1,"event":"stmt","line":2,"stmt":"ReturnStmt"}
+{"depth":1,"event":"return","function":"double","line":2,"value":2}
+{"args":[2],"depth":1,"event":"call","function":"double","line":5}
+{"depth":1,"event":"stmt","line":2,"stmt":"ReturnStmt"}
+{"depth":1,"event":"return","function":"double","line":2,"value":4}
+`
+
+	if trace, _ := traceScript(t, source, "double", 0); trace != filtered {
+		t.Errorf("expected\n%s\ngot\n%s", filtered, trace)
+	}
+
+	truncated := `{"depth":0,"event":"stmt","line":1,"stmt":"FunStmt"}
+{"event":"truncated","limit":100}
+`
+
+	if trace, _ := traceScript(t, source, "", 100); trace != truncated {
+		t.Errorf("expected\n%s\ngot\n%s", truncated, trace)
+	}
+}
+
+func TestTraceCyclicValue(t *testing.T) {
+	source := `class Loud {
+  toString() { print "called"; return "loud"; }
+}
+var l = [Loud()];
+l.push(l);
+var same = l;
+`
+
+	expected := `{"depth":0,"event":"stmt","line":1,"stmt":"ClassStmt"}
+{"depth":0,"event":"define","line":1,"name":"Loud","value":"<class Loud>"}
+{"depth":0,"event":"stmt","line":4,"stmt":"VarStmt"}
+{"depth":0,"event":"define","line":4,"name":"l","value":"[instance of Loud]"}
+{"depth":0,"event":"stmt","line":5,"stmt":"ExprStmt"}
+{"depth":0,"event":"stmt","line":6,"stmt":"VarStmt"}
+{"depth":0,"event":"define","line":6,"name":"same","value":"[instance of Loud, [...]]"}
+`
+
+	// calling "toString()" would add its statements to the trace
+	trace, err := traceScript(t, source, "", 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trace != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, trace)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Type is what Checker knows about a value. Names are the ones
+// used in annotations: builtin types or class names for instances.
+type Type struct {
+	name string
+	// known signature of functions and classes
+	fn *Signature
+}
+
+// Signature of a function or a class, calling
+// a class returns an instance of that class.
+type Signature struct {
+	name string
+	// types of positional parameters
+	params   []*Type
+	min, max int
+	returns  *Type
+}
+
+var builtinTypes = []string{
+	"any", "number", "string", "bool", "nil", "list", "map",
+	"fun", "task", "channel", "generator", "range", "regex",
+}
+
+var anyType = &Type{name: "any"}
+
+func (t *Type) String() string {
+	return t.name
+}
+
+// assignable reports whether value of type from can be used where
+// type to is expected, "any" is compatible with everything.
+func assignable(to *Type, from *Type) bool {
+	return to.name == "any" || from.name == "any" || to.name == from.name
+}
+
+// typeOf names type of runtime value the way annotations do.
+func typeOf(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *List:
+		return "list"
+	case *Map:
+		return "map"
+	case *ClassInstance:
+		return v.klass.name
+	case *Task:
+		return "task"
+	case *Channel:
+		return "channel"
+	case *Generator:
+		return "generator"
+	case *Range:
+		return "range"
+	case *Regex:
+		return "regex"
+	case Callable:
+		return "fun"
+	}
+
+	return "any"
+}
+
+// checkType is run in strict mode where annotations are:
+// variable initializers, arguments and return values.
+// Mismatch is thrown and reported with false.
+func (i *Interpreter) checkType(token Token, typ *Annotation, val any) bool {
+	if typ == nil || !i.strict {
+		return true
+	}
+
+	expected := typ.name.lexeme
+
+	if expected == "any" || typeOf(val) == expected {
+		return true
+	}
+
+	i.throw(&RuntimeError{token, fmt.Sprintf("Expected %s but got %s.", expected, typeOf(val))})
+	return false
+}
+
+func isBuiltinType(name string) bool {
+	return slices.Contains(builtinTypes, name)
+}